@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/slashtechno/cross-blogger/internal/platforms"
+)
+
+// Wrap a management API handler so it requires the API token as a bearer token.
+// If the token isn't set, read-only endpoints are open and the others are disabled.
+func (s *server) requireToken(readOnly bool, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.apiToken == "" {
+			if !readOnly {
+				writeError(w, http.StatusForbidden, "set api_token in the credentials file to use this endpoint")
+				return
+			}
+		} else if !validBearer(r.Header.Get("Authorization"), s.apiToken) {
+			writeError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// A source or destination as listed by the API, with the optional features it supports
+type platformInfo struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Features []string `json:"features"`
+}
+
+func (s *server) handleSources(w http.ResponseWriter, r *http.Request) {
+	sources := []platformInfo{}
+	for _, source := range s.sourceSlice {
+		info := platformInfo{Name: source.GetName(), Type: source.GetType(), Features: []string{}}
+		if _, ok := source.(platforms.ListableSource); ok {
+			info.Features = append(info.Features, "list")
+		}
+		if _, ok := source.(platforms.WatchableSource); ok {
+			info.Features = append(info.Features, "watch")
+		}
+		sources = append(sources, info)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"sources": sources})
+}
+
+func (s *server) handleDestinations(w http.ResponseWriter, r *http.Request) {
+	destinations := []platformInfo{}
+	for _, destination := range s.destinationSlice {
+		info := platformInfo{Name: destination.GetName(), Type: destination.GetType(), Features: []string{}}
+		if _, ok := destination.(platforms.DeletableDestination); ok {
+			info.Features = append(info.Features, "delete")
+		}
+		if _, ok := destination.(platforms.PreviewableDestination); ok {
+			info.Features = append(info.Features, "preview")
+		}
+		destinations = append(destinations, info)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"destinations": destinations})
+}
+
+// The body of a publish or preview request
+type apiPublishRequest struct {
+	Source string `json:"source"`
+	// Specifier is the post to publish, such as a Blogger post URL or a file path, the same as with the publish command
+	Specifier    string   `json:"specifier"`
+	Destinations []string `json:"destinations"`
+}
+
+// Decode and check a publish or preview request.
+// If no destinations are given, every configured destination is used.
+func (s *server) decodePublishRequest(w http.ResponseWriter, r *http.Request) (apiPublishRequest, error) {
+	var request apiPublishRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxHookBodySize)).Decode(&request); err != nil {
+		return apiPublishRequest{}, fmt.Errorf("invalid request: %w", err)
+	}
+	if _, ok := s.sources[request.Source]; !ok {
+		return apiPublishRequest{}, fmt.Errorf("source %q not found", request.Source)
+	}
+	if request.Specifier == "" {
+		return apiPublishRequest{}, errors.New("specifier is required")
+	}
+	if _, markdown := s.sources[request.Source].(*platforms.Markdown); markdown {
+		if err := checkPostPath(request.Specifier); err != nil {
+			return apiPublishRequest{}, err
+		}
+	}
+	if len(request.Destinations) == 0 {
+		for _, destination := range s.destinationSlice {
+			request.Destinations = append(request.Destinations, destination.GetName())
+		}
+	}
+	for _, destination := range request.Destinations {
+		if _, ok := s.destinations[destination]; !ok {
+			return apiPublishRequest{}, fmt.Errorf("destination %q not found", destination)
+		}
+	}
+	return request, nil
+}
+
+// Queue a job to publish a post
+func (s *server) handleApiPublish(w http.ResponseWriter, r *http.Request) {
+	request, err := s.decodePublishRequest(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	queued, err := s.jobs.enqueue(job{
+		Trigger:      "api",
+		Source:       request.Source,
+		Specifier:    request.Specifier,
+		Destinations: request.Destinations,
+		updateSource: true,
+	})
+	if errors.Is(err, errQueueFull) {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Info("Queued post", "trigger", "api", "job", queued.Id, "specifier", request.Specifier)
+	writeJSON(w, http.StatusAccepted, queued)
+}
+
+// What a post would look like on a destination
+type destinationPreview struct {
+	Name string             `json:"name"`
+	Type string             `json:"type"`
+	Post platforms.PostData `json:"post"`
+	// Output is what the destination would write, for destinations that can preview it, such as the file for Markdown
+	Output string `json:"output,omitempty"`
+}
+
+// Pull a post and return it as it would be pushed to each destination, without pushing it
+func (s *server) handlePreview(w http.ResponseWriter, r *http.Request) {
+	request, err := s.decodePublishRequest(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	source := s.sources[request.Source]
+	options, err := sourceOptions(source)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	specifier, err := postPathInContent(source, request.Specifier)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	setSpecifier(source, &options, specifier)
+	postData, err := source.Pull(options)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "failed to pull post: "+err.Error())
+		return
+	}
+	postData.Source = source.GetName()
+
+	destinationSlice := []platforms.Destination{}
+	for _, name := range request.Destinations {
+		destinationSlice = append(destinationSlice, s.destinations[name])
+	}
+	links := buildLinkIndex(source, options, destinationSlice)
+	previews := []destinationPreview{}
+	for _, destination := range destinationSlice {
+		prepared, err := preparePost(postData, destination, links)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		preview := destinationPreview{Name: destination.GetName(), Type: destination.GetType(), Post: prepared}
+		if previewable, ok := destination.(platforms.PreviewableDestination); ok {
+			preview.Output, err = previewable.Preview(prepared)
+			if err != nil {
+				writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("failed to preview post for %s: %s", destination.GetName(), err))
+				return
+			}
+		}
+		previews = append(previews, preview)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"post": postData, "destinations": previews})
+}
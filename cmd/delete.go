@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/slashtechno/cross-blogger/internal"
+	"github.com/slashtechno/cross-blogger/internal/platforms"
+	"github.com/spf13/cobra"
+)
+
+var deleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete a post from destinations",
+	Long: `Delete a post that was published to one or more destinations.
+	The first positional argument is the post: its canonical URL or, if it doesn't have one, its title.
+	The second positional argument and on are treated as destination names.
+	Only destinations that support deleting posts, such as Micropub, can be used.`,
+	// Arg 1: Post
+	// Arg 2+: Destinations
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, destinationSlice, err := platforms.Load(internal.ConfigViper.Get("sources"), internal.ConfigViper.Get("destinations"), nil, args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		// Destinations remember posts by canonical URL, falling back to the title
+		postData := platforms.PostData{Title: args[0]}
+		if parsed, err := url.Parse(args[0]); err == nil && parsed.Scheme != "" && parsed.Host != "" {
+			postData = platforms.PostData{CanonicalUrl: args[0]}
+		}
+		failed := false
+		for _, destination := range destinationSlice {
+			deletable, ok := destination.(platforms.DeletableDestination)
+			if !ok {
+				log.Error("Destination can't delete posts", "destination", destination.GetName(), "type", destination.GetType())
+				failed = true
+				continue
+			}
+			options, _, err := destinationOptions(destination, nil)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if dryRun {
+				log.Info("Skipping delete due to dry run", "destination", destination.GetName())
+				continue
+			}
+			start := time.Now()
+			err = deletable.Delete(postData, options)
+			if auditErr := platforms.Audit.RecordDelete(destination, postData, err); auditErr != nil {
+				log.Error("Failed to write to the audit log", "error", auditErr)
+			}
+			if err != nil {
+				log.Error("Failed to delete post", append(platforms.LogFields(postData), "destination", destination.GetName(), "error", err)...)
+				failed = true
+				continue
+			}
+			log.Info("Deleted post", append(platforms.LogFields(postData), "destination", destination.GetName(), "duration", time.Since(start))...)
+		}
+		if failed {
+			log.Fatal("Failed to delete the post from every destination")
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(deleteCmd)
+	deleteCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Dry run - don't actually delete the post")
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var goroutineUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "cross_blogger_goroutine_up",
+	Help: "Whether a long-running goroutine, such as a source's watcher, is still running (1) or has stopped (0).",
+}, []string{"goroutine"})
+
+// goroutineHealth keeps track of whether a command's long-running goroutines are still running.
+// They stop when they run into an error, and the command is only healthy while all of them are running.
+type goroutineHealth struct {
+	lock sync.Mutex
+	// running maps the name of each goroutine to whether it's still running
+	running map[string]bool
+	// The last error any goroutine reported
+	lastError     string
+	lastErrorTime time.Time
+}
+
+func newGoroutineHealth() *goroutineHealth {
+	return &goroutineHealth{running: map[string]bool{}}
+}
+
+// Record that a goroutine started
+func (h *goroutineHealth) start(name string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.running[name] = true
+	goroutineUp.WithLabelValues(name).Set(1)
+}
+
+// Record that a goroutine stopped
+func (h *goroutineHealth) stop(name string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.running[name] = false
+	goroutineUp.WithLabelValues(name).Set(0)
+	log.Error("Goroutine stopped; the health check will now fail", "goroutine", name)
+}
+
+// Record an error reported by a goroutine
+func (h *goroutineHealth) recordError(err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.lastError = err.Error()
+	h.lastErrorTime = time.Now().UTC()
+}
+
+// Respond with 200 if every goroutine is running, or 503 if any have stopped
+func (h *goroutineHealth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.lock.Lock()
+	status := "ok"
+	goroutines := map[string]string{}
+	for name, running := range h.running {
+		goroutines[name] = "running"
+		if !running {
+			goroutines[name] = "stopped"
+			status = "unhealthy"
+		}
+	}
+	response := map[string]interface{}{"status": status, "goroutines": goroutines}
+	if h.lastError != "" {
+		response["last_error"] = h.lastError
+		response["last_error_time"] = h.lastErrorTime
+	}
+	h.lock.Unlock()
+
+	if status != "ok" {
+		writeJSON(w, http.StatusServiceUnavailable, response)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
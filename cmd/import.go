@@ -0,0 +1,372 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/slashtechno/cross-blogger/internal"
+	"github.com/slashtechno/cross-blogger/internal/platforms"
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import every post from a source to destinations",
+	Long: `Import every post from a source to one or more destinations, such as to migrate a whole blog.
+	Specify the source with the first positional argument.
+	The second positional argument and on are treated as destination names.
+	Posts can be narrowed down by date, label, and status, and routes in the config file decide which of the destinations each post is pushed to.
+	If a checkpoint file is set, posts that were already imported are skipped so an interrupted import can be resumed.
+	Markdown destinations with git_dir set commit every imported post at once when the import finishes.`,
+	// Arg 1: Source
+	// Arg 2+: Destinations
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		sourceSlice, destinationSlice, err := platforms.Load(internal.ConfigViper.Get("sources"), internal.ConfigViper.Get("destinations"), []string{args[0]}, args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		// As with publish.go, iterate over the sources to ensure that the source matches the first argument
+		var source platforms.Source
+		for _, s := range sourceSlice {
+			if s.GetName() == args[0] {
+				source = s
+				break
+			}
+		}
+		if source == nil {
+			log.Fatal("Source not found", "source", args[0])
+		}
+		listable, ok := source.(platforms.ListableSource)
+		if !ok {
+			log.Fatal("Source can't list its posts", "source", args[0])
+		}
+		filter, err := importFilter()
+		if err != nil {
+			log.Fatal(err)
+		}
+		routes, err := loadRoutes()
+		if err != nil {
+			log.Fatal(err)
+		}
+		checkpoint, err := loadImportCheckpoint(importCheckpointPath, source.GetName())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		options, err := sourceOptions(source)
+		if err != nil {
+			log.Fatal(err)
+		}
+		posts, err := listable.ListPosts(options, filter)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Info("Found posts to import", "source", source.GetName(), "posts", len(posts))
+
+		links := buildLinkIndex(source, options, destinationSlice)
+		// Posts are pushed concurrently, so Markdown destinations with Git always batch their commits
+		var batches gitBatches
+		if !dryRun {
+			batches, err = beginGitBatches(destinationSlice, true)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		// Authorize with each destination before pushing concurrently, so the workers share the cached credentials
+		for _, destination := range destinationSlice {
+			if _, _, err := destinationOptions(destination, nil); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		report := importReport{}
+		// Limit the number of posts being imported at once
+		semaphore := make(chan struct{}, max(importConcurrency, 1))
+		var wg sync.WaitGroup
+		for _, post := range posts {
+			// Only push to destinations the post hasn't already been imported to
+			remaining := []platforms.Destination{}
+			for _, destination := range destinationSlice {
+				if !checkpoint.imported(post.Id, destination.GetName()) {
+					remaining = append(remaining, destination)
+				}
+			}
+			if len(remaining) == 0 {
+				log.Debug("Skipping post that was already imported", "title", post.Title)
+				report.skip()
+				continue
+			}
+			wg.Add(1)
+			semaphore <- struct{}{}
+			go func(post platforms.ListedPost, remaining []platforms.Destination) {
+				defer wg.Done()
+				defer func() { <-semaphore }()
+				postData, err := listable.PullListed(post, options)
+				if err != nil {
+					log.Error("Failed to pull post", "source", source.GetName(), "post_id", post.Id, "title", post.Title, "error", err)
+					report.fail(post, "", err)
+					return
+				}
+				postData.Source = source.GetName()
+				remaining = routeDestinations(routes, postData, source, remaining)
+				if len(remaining) == 0 {
+					report.skip()
+					return
+				}
+				failed := false
+				for _, destination := range remaining {
+					err := pushToDestinations(postData, []platforms.Destination{destination}, links, batches, dryRun)
+					if err != nil {
+						log.Error("Failed to import post", append(platforms.LogFields(postData), "title", post.Title, "destination", destination.GetName(), "error", err)...)
+						report.fail(post, destination.GetName(), err)
+						failed = true
+						continue
+					}
+					if dryRun {
+						continue
+					}
+					// Batched changes aren't recorded until they've been committed
+					if _, batched := batches[destination.GetName()]; batched {
+						checkpoint.pending(post.Id, destination.GetName())
+					} else if err := checkpoint.record(post.Id, destination.GetName()); err != nil {
+						log.Error("Failed to save checkpoint", "error", err)
+					}
+				}
+				if !failed {
+					log.Info("Imported post", append(platforms.LogFields(postData), "title", post.Title)...)
+					report.succeed()
+				}
+			}(post, remaining)
+		}
+		wg.Wait()
+
+		if !dryRun {
+			if err := batches.commit(destinationSlice); err != nil {
+				log.Fatal("Failed to commit imported posts; run the import again to retry", "error", err)
+			}
+			if err := checkpoint.recordPending(); err != nil {
+				log.Error("Failed to save checkpoint", "error", err)
+			}
+		}
+
+		log.Info("Import finished", "imported", report.Imported, "skipped", report.Skipped, "failed", len(report.Failures))
+		for _, failure := range report.Failures {
+			log.Error("Failed", "title", failure.Title, "id", failure.Id, "destination", failure.Destination, "error", failure.Err)
+		}
+		if len(report.Failures) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+var (
+	importSince          string
+	importUntil          string
+	importLabels         []string
+	importStatuses       []string
+	importConcurrency    int
+	importCheckpointPath string
+)
+
+func init() {
+	RootCmd.AddCommand(importCmd)
+	importCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Dry run - pull every post but don't push them")
+	importCmd.Flags().StringVar(&importSince, "since", "", "Only import posts published on or after this date (YYYY-MM-DD or RFC 3339)")
+	importCmd.Flags().StringVar(&importUntil, "until", "", "Only import posts published on or before this date (YYYY-MM-DD or RFC 3339)")
+	importCmd.Flags().StringSliceVar(&importLabels, "label", nil, "Only import posts with one of these labels (categories and tags for Markdown)")
+	importCmd.Flags().StringSliceVar(&importStatuses, "status", []string{"live"}, "Statuses of posts to import (\"live\", \"draft\", or \"scheduled\")")
+	importCmd.Flags().IntVarP(&importConcurrency, "concurrency", "c", 4, "Number of posts to import at once")
+	importCmd.Flags().StringVar(&importCheckpointPath, "checkpoint", "", "File to record imported posts in so an interrupted import can be resumed")
+}
+
+// Build the post filter from the flags
+func importFilter() (platforms.PostFilter, error) {
+	filter := platforms.PostFilter{
+		Labels:   importLabels,
+		Statuses: importStatuses,
+	}
+	for _, status := range importStatuses {
+		switch status {
+		case "live", "draft", "scheduled":
+		default:
+			return platforms.PostFilter{}, fmt.Errorf("unknown status: %s", status)
+		}
+	}
+	var err error
+	if importSince != "" {
+		filter.Since, err = parseImportDate(importSince, false)
+		if err != nil {
+			return platforms.PostFilter{}, err
+		}
+	}
+	if importUntil != "" {
+		filter.Until, err = parseImportDate(importUntil, true)
+		if err != nil {
+			return platforms.PostFilter{}, err
+		}
+	}
+	return filter, nil
+}
+
+// Parse a date from a flag. If only a day is given and endOfDay is true, the start of the next day is returned so the whole day is included.
+func parseImportDate(value string, endOfDay bool) (time.Time, error) {
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, nil
+	}
+	date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: expected YYYY-MM-DD or RFC 3339", value)
+	}
+	if endOfDay {
+		date = date.AddDate(0, 0, 1)
+	}
+	return date, nil
+}
+
+// importReport counts the outcome of each post. It's safe to use from multiple goroutines.
+type importReport struct {
+	mu       sync.Mutex
+	Imported int
+	Skipped  int
+	Failures []importFailure
+}
+
+type importFailure struct {
+	Id          string
+	Title       string
+	Destination string
+	Err         error
+}
+
+func (r *importReport) succeed() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Imported++
+}
+
+func (r *importReport) skip() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Skipped++
+}
+
+func (r *importReport) fail(post platforms.ListedPost, destination string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Failures = append(r.Failures, importFailure{Id: post.Id, Title: post.Title, Destination: destination, Err: err})
+}
+
+// importCheckpoint records which destinations each post has been imported to.
+// If path is empty, nothing is written and every post is imported.
+type importCheckpoint struct {
+	mu   sync.Mutex
+	path string
+	// Changes waiting on a Git batch to be committed, keyed by post ID
+	waiting map[string][]string
+
+	Source string `json:"source"`
+	// Posts maps the ID of each post to the destinations it was imported to
+	Posts map[string][]string `json:"posts"`
+}
+
+// Load the checkpoint file if it exists. It must have been written by an import from the same source.
+func loadImportCheckpoint(path string, source string) (*importCheckpoint, error) {
+	checkpoint := &importCheckpoint{
+		path:    path,
+		waiting: map[string][]string{},
+		Source:  source,
+		Posts:   map[string][]string{},
+	}
+	if path == "" {
+		return checkpoint, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint %s: %w", path, err)
+	}
+	if checkpoint.Source != source {
+		return nil, fmt.Errorf("checkpoint %s is for source %s, not %s", path, checkpoint.Source, source)
+	}
+	if checkpoint.Posts == nil {
+		checkpoint.Posts = map[string][]string{}
+	}
+	log.Info("Resuming from checkpoint", "path", path, "posts", len(checkpoint.Posts))
+	return checkpoint, nil
+}
+
+// Check if a post has already been imported to a destination
+func (c *importCheckpoint) imported(id string, destination string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, d := range c.Posts[id] {
+		if d == destination {
+			return true
+		}
+	}
+	return false
+}
+
+// Record that a post was imported to a destination and save the checkpoint
+func (c *importCheckpoint) record(id string, destination string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Posts[id] = append(c.Posts[id], destination)
+	return c.save()
+}
+
+// Hold on to an import until the Git batch it's in has been committed
+func (c *importCheckpoint) pending(id string, destination string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waiting[id] = append(c.waiting[id], destination)
+}
+
+// Record every pending import and save the checkpoint
+func (c *importCheckpoint) recordPending() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, destinations := range c.waiting {
+		c.Posts[id] = append(c.Posts[id], destinations...)
+	}
+	c.waiting = map[string][]string{}
+	return c.save()
+}
+
+// Write the checkpoint to a temporary file and rename it so an interruption can't leave a partial file behind.
+// The caller must hold the lock.
+func (c *importCheckpoint) save() error {
+	if c.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
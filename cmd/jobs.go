@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// The states a job goes through
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobSucceeded = "succeeded"
+	jobFailed    = "failed"
+)
+
+// Returned by enqueue when there's no room for another job
+var errQueueFull = errors.New("the job queue is full")
+
+// A job publishes one post from a source to a set of destinations
+type job struct {
+	Id string `json:"id"`
+	// Trigger is what created the job, such as the name of a hook
+	Trigger      string   `json:"trigger"`
+	Source       string   `json:"source"`
+	Specifier    string   `json:"specifier"`
+	Destinations []string `json:"destinations"`
+	Status       string   `json:"status"`
+	// Title is the title of the post, once it has been pulled
+	Title    string     `json:"title,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Created  time.Time  `json:"created"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	// If updateSource is true, a Markdown source's Git repository is pulled before the post is read
+	updateSource bool
+}
+
+// jobQueue runs jobs with a fixed number of workers and remembers the most recent ones
+type jobQueue struct {
+	lock sync.Mutex
+	jobs map[string]*job
+	// order has the IDs of the remembered jobs, oldest first
+	order []string
+	// history is how many finished jobs are remembered
+	history int
+	pending chan *job
+	// workers is how many jobs can run at once
+	workers int
+	// run does the work of a job, returning the title of the post
+	run func(job) (string, error)
+	wg  sync.WaitGroup
+}
+
+// Start concurrency workers. Up to size jobs can wait for a worker.
+func newJobQueue(concurrency int, size int, history int, run func(job) (string, error)) *jobQueue {
+	q := &jobQueue{
+		jobs:    map[string]*job{},
+		history: history,
+		pending: make(chan *job, max(size, 0)),
+		workers: max(concurrency, 1),
+		run:     run,
+	}
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// Run jobs until the queue is closed
+func (q *jobQueue) work() {
+	defer q.wg.Done()
+	for j := range q.pending {
+		q.lock.Lock()
+		started := time.Now().UTC()
+		j.Status = jobRunning
+		j.Started = &started
+		copied := *j
+		q.lock.Unlock()
+
+		title, err := q.runSafely(copied)
+
+		q.lock.Lock()
+		finished := time.Now().UTC()
+		j.Finished = &finished
+		j.Title = title
+		if err != nil {
+			j.Status = jobFailed
+			j.Error = err.Error()
+		} else {
+			j.Status = jobSucceeded
+		}
+		q.trim()
+		q.lock.Unlock()
+	}
+}
+
+// Run a job, turning a panic into an error so one bad post can't take down the server
+func (q *jobQueue) runSafely(j job) (title string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("Job panicked", "job", j.Id, "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return q.run(j)
+}
+
+// Fill in the ID and status of a new job
+func prepareJob(j job) (job, error) {
+	id, err := newJobId()
+	if err != nil {
+		return job{}, err
+	}
+	j.Id = id
+	j.Status = jobQueued
+	j.Created = time.Now().UTC()
+	return j, nil
+}
+
+// Add a job to the queue, filling in its ID and status
+func (q *jobQueue) enqueue(j job) (job, error) {
+	j, err := prepareJob(j)
+	if err != nil {
+		return job{}, err
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	select {
+	case q.pending <- &j:
+	default:
+		return job{}, errQueueFull
+	}
+	q.jobs[j.Id] = &j
+	q.order = append(q.order, j.Id)
+	q.trim()
+	return j, nil
+}
+
+// Add several jobs to the queue, or none of them if there isn't room for all of them
+func (q *jobQueue) enqueueAll(jobs []job) ([]job, error) {
+	queued := make([]job, 0, len(jobs))
+	for _, j := range jobs {
+		j, err := prepareJob(j)
+		if err != nil {
+			return nil, err
+		}
+		queued = append(queued, j)
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	// Workers only take jobs out of the queue, so once there's room for every job, sending them can't block
+	if cap(q.pending)-len(q.pending) < len(queued) {
+		return nil, errQueueFull
+	}
+	for _, j := range queued {
+		q.pending <- &j
+		q.jobs[j.Id] = &j
+		q.order = append(q.order, j.Id)
+	}
+	q.trim()
+	return queued, nil
+}
+
+// Forget the oldest finished jobs once there are more than history of them. The caller must hold the lock.
+func (q *jobQueue) trim() {
+	finished := 0
+	for _, id := range q.order {
+		if q.jobs[id].Finished != nil {
+			finished++
+		}
+	}
+	kept := q.order[:0]
+	for _, id := range q.order {
+		if finished > q.history && q.jobs[id].Finished != nil {
+			delete(q.jobs, id)
+			finished--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	q.order = kept
+}
+
+// Return a copy of the job with the ID
+func (q *jobQueue) get(id string) (job, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return job{}, false
+	}
+	return *j, true
+}
+
+// Return copies of the remembered jobs, newest first
+func (q *jobQueue) list() []job {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	jobs := make([]job, 0, len(q.order))
+	for i := len(q.order) - 1; i >= 0; i-- {
+		jobs = append(jobs, *q.jobs[q.order[i]])
+	}
+	return jobs
+}
+
+// queueHealth is a snapshot of how busy the queue is, for the health check
+type queueHealth struct {
+	Workers   int `json:"workers"`
+	Running   int `json:"running"`
+	Queued    int `json:"queued"`
+	QueueSize int `json:"queue_size"`
+	// Stuck has the IDs of jobs that have been running for longer than the threshold given to health
+	Stuck []string `json:"stuck,omitempty"`
+}
+
+// Healthy is false if the queue is full, so hooks are being rejected, or a job seems to be stuck
+func (h queueHealth) Healthy() bool {
+	full := h.Queued >= h.QueueSize && h.Running >= h.Workers
+	return !full && len(h.Stuck) == 0
+}
+
+// Count the running and queued jobs. Jobs that have been running for longer than stuckAfter are considered stuck, unless stuckAfter is 0.
+func (q *jobQueue) health(stuckAfter time.Duration) queueHealth {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	h := queueHealth{Workers: q.workers, Queued: len(q.pending), QueueSize: cap(q.pending)}
+	for _, id := range q.order {
+		j := q.jobs[id]
+		if j.Status != jobRunning {
+			continue
+		}
+		h.Running++
+		if stuckAfter > 0 && time.Since(*j.Started) > stuckAfter {
+			h.Stuck = append(h.Stuck, j.Id)
+		}
+	}
+	return h
+}
+
+// Stop accepting jobs and wait for the queued ones to finish
+func (q *jobQueue) close() {
+	q.lock.Lock()
+	close(q.pending)
+	q.lock.Unlock()
+	q.wg.Wait()
+}
+
+// Return a random ID for a job
+func newJobId() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestJobQueueRecoversFromPanics(t *testing.T) {
+	q := newJobQueue(1, 10, 10, func(j job) (string, error) {
+		switch j.Specifier {
+		case "panic":
+			var frontmatter *struct{ Title string }
+			return frontmatter.Title, nil
+		case "fail":
+			return "", errors.New("failed")
+		}
+		return "Title", nil
+	})
+	ids := []string{}
+	for _, specifier := range []string{"panic", "fail", "ok"} {
+		queued, err := q.enqueue(job{Specifier: specifier})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, queued.Id)
+	}
+	// Closing waits for the queued jobs, so it would hang if the worker died
+	q.close()
+
+	tests := []struct {
+		status string
+		error  string
+	}{
+		{status: jobFailed, error: "job panicked"},
+		{status: jobFailed, error: "failed"},
+		{status: jobSucceeded},
+	}
+	for i, tt := range tests {
+		j, ok := q.get(ids[i])
+		if !ok {
+			t.Fatalf("job %s not found", ids[i])
+		}
+		if j.Status != tt.status || !strings.HasPrefix(j.Error, tt.error) || (tt.error == "" && j.Error != "") {
+			t.Errorf("job %s finished as %s with error %q, want %s with %q", j.Specifier, j.Status, j.Error, tt.status, tt.error)
+		}
+	}
+}
+
+func TestJobQueueEnqueueAll(t *testing.T) {
+	release := make(chan struct{})
+	q := newJobQueue(1, 2, 10, func(j job) (string, error) {
+		<-release
+		return "", nil
+	})
+	// Keep the worker busy so the other jobs wait in the queue
+	if _, err := q.enqueue(job{Specifier: "busy"}); err != nil {
+		t.Fatal(err)
+	}
+	for q.health(0).Running == 0 {
+		runtime.Gosched()
+	}
+
+	if _, err := q.enqueueAll([]job{{Specifier: "a"}, {Specifier: "b"}, {Specifier: "c"}}); !errors.Is(err, errQueueFull) {
+		t.Fatalf("enqueueAll() error = %v, want %v", err, errQueueFull)
+	}
+	if jobs := q.list(); len(jobs) != 1 {
+		t.Fatalf("%d jobs were remembered after the queue was full, want only the running one", len(jobs))
+	}
+	queued, err := q.enqueueAll([]job{{Specifier: "a"}, {Specifier: "b"}})
+	if err != nil {
+		t.Fatalf("enqueueAll() error = %v", err)
+	}
+	if len(queued) != 2 || queued[0].Id == "" || queued[0].Id == queued[1].Id || queued[1].Status != jobQueued {
+		t.Fatalf("enqueueAll() = %+v, want two queued jobs with their own IDs", queued)
+	}
+	close(release)
+	q.close()
+	for _, j := range queued {
+		if got, _ := q.get(j.Id); got.Status != jobSucceeded {
+			t.Errorf("job %s finished as %s, want %s", j.Specifier, got.Status, jobSucceeded)
+		}
+	}
+}
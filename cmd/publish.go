@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/slashtechno/cross-blogger/internal"
@@ -47,38 +50,26 @@ var publishCmd = &cobra.Command{
 		if !found {
 			log.Fatal("Source not found", "source", args[0])
 		}
-		// Pull the data from the source
-		var options platforms.PushPullOptions
-		switch source.GetType() {
-		case "blogger":
-			_, accessToken, blogId, _, err := prepareBlogger(source, nil, internal.CredentialViper.GetString("google_client_id"), internal.CredentialViper.GetString("google_client_secret"), internal.CredentialViper.GetString("google_refresh_token"))
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			options = platforms.PushPullOptions{
-				AccessToken: accessToken,
-				BlogId:      blogId,
-				PostUrl:     args[1],
-				LlmProvider: internal.CredentialViper.GetString("llm_provider"),
-				LlmBaseUrl:  internal.CredentialViper.GetString("llm_base_url"),
-				LlmApiKey:   internal.CredentialViper.GetString("llm_api_key"),
-				LlmModel:    internal.CredentialViper.GetString("llm_model"),
-			}
-		case "markdown":
-			options = platforms.PushPullOptions{
-				Filepath: args[1],
-			}
+		// Get the options needed to pull the post
+		options, err := sourceOptions(source)
+		if err != nil {
+			log.Fatal(err)
 		}
+		setSpecifier(source, &options, args[1])
 		// Pull the data from the source
 		postData, err := source.Pull(options)
 		if err != nil {
 			log.Fatal(err)
 		}
-		log.Info("Successfully pulled data", "title", postData.Title, "url", postData.CanonicalUrl, "markdown", postData.Markdown)
+		postData.Source = source.GetName()
+		log.Info("Successfully pulled data", append(platforms.LogFields(postData), "title", postData.Title, "url", postData.CanonicalUrl)...)
+		log.Debug("Pulled Markdown", "markdown", postData.Markdown)
+
+		// Build an index of the source's posts if any destination rewrites links between them
+		links := buildLinkIndex(source, options, destinationSlice)
 
 		// For each destination, push the data
-		err = pushToDestinations(postData, destinationSlice, dryRun)
+		err = pushToDestinations(postData, destinationSlice, links, nil, dryRun)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -98,6 +89,13 @@ func init() {
 	publishCmd.PersistentFlags().String("llm-base-url", "", "Base URL")
 	publishCmd.PersistentFlags().String("llm-api-key", "", "OpenAI API key")
 	publishCmd.PersistentFlags().String("llm-model", "", "LLM model to use for OpenAI-compatible platforms")
+	publishCmd.PersistentFlags().String("forge-token", "", "GitHub or Gitea token used to open pull requests")
+	publishCmd.PersistentFlags().String("git-token", "", "Token used to pull and push over HTTPS")
+	publishCmd.PersistentFlags().String("mastodon-token", "", "Access token for Mastodon destinations")
+	publishCmd.PersistentFlags().String("bluesky-app-password", "", "App password for Bluesky destinations")
+	publishCmd.PersistentFlags().String("hashnode-token", "", "Personal access token for Hashnode destinations")
+	publishCmd.PersistentFlags().String("medium-token", "", "Integration token for Medium destinations")
+	publishCmd.PersistentFlags().String("micropub-token", "", "IndieAuth token for Micropub destinations")
 	// Allow the OAuth stuff to be set via viper
 	internal.CredentialViper.BindPFlag("google_client_id", publishCmd.Flags().Lookup("google-client-id"))
 	internal.CredentialViper.BindPFlag("google_client_secret", publishCmd.Flags().Lookup("google-client-secret"))
@@ -107,6 +105,16 @@ func init() {
 	internal.CredentialViper.BindPFlag("llm_base_url", publishCmd.Flags().Lookup("llm-base-url"))
 	internal.CredentialViper.BindPFlag("llm_api_key", publishCmd.Flags().Lookup("llm-api-key"))
 	internal.CredentialViper.BindPFlag("llm_model", publishCmd.Flags().Lookup("llm-model"))
+	// Bind Viper to the Git flags
+	internal.CredentialViper.BindPFlag("forge_token", publishCmd.Flags().Lookup("forge-token"))
+	internal.CredentialViper.BindPFlag("git_token", publishCmd.Flags().Lookup("git-token"))
+	// Bind Viper to the flags for announcement destinations
+	internal.CredentialViper.BindPFlag("mastodon_token", publishCmd.Flags().Lookup("mastodon-token"))
+	internal.CredentialViper.BindPFlag("bluesky_app_password", publishCmd.Flags().Lookup("bluesky-app-password"))
+	// Bind Viper to the flags for API destinations
+	internal.CredentialViper.BindPFlag("hashnode_token", publishCmd.Flags().Lookup("hashnode-token"))
+	internal.CredentialViper.BindPFlag("medium_token", publishCmd.Flags().Lookup("medium-token"))
+	internal.CredentialViper.BindPFlag("micropub_token", publishCmd.Flags().Lookup("micropub-token"))
 }
 
 // Return the Blogger object and a string with the access token, the blog ID, a refresh token, and an error if one occurred
@@ -145,7 +153,7 @@ func prepareBlogger(source platforms.Source, destination platforms.Destination,
 		}
 		// Write the refresh token to the config file
 		log.Info("Writing refresh token to Viper")
-		internal.CredentialViper.Set("google-refresh-token", refreshToken)
+		internal.CredentialViper.Set("google_refresh_token", refreshToken)
 		// The flag viper should be .env only
 		err = internal.CredentialViper.WriteConfig()
 		if err != nil {
@@ -166,29 +174,191 @@ func prepareBlogger(source platforms.Source, destination platforms.Destination,
 	return *blogger, accessToken, blogId, refreshToken, nil
 }
 
+// How long authorized options are reused for. Access tokens from Google and Bluesky last at least an hour.
+const credentialLifetime = 30 * time.Minute
+
+type credentialCacheEntry struct {
+	options platforms.PushPullOptions
+	created time.Time
+}
+
+var (
+	credentialCacheLock sync.Mutex
+	credentialCache     = map[string]credentialCacheEntry{}
+)
+
+// Return the destination's authorized options, only calling authorize if they haven't been cached or are too old.
+// Pushes can run concurrently (such as with import), so this also keeps them from authorizing, and writing the credentials file, at the same time.
+func cachedCredentials(destination platforms.Destination, authorize func() (platforms.PushPullOptions, error)) (platforms.PushPullOptions, error) {
+	credentialCacheLock.Lock()
+	defer credentialCacheLock.Unlock()
+	if entry, ok := credentialCache[destination.GetName()]; ok && time.Since(entry.created) < credentialLifetime {
+		return entry.options, nil
+	}
+	options, err := authorize()
+	if err != nil {
+		return platforms.PushPullOptions{}, err
+	}
+	credentialCache[destination.GetName()] = credentialCacheEntry{options: options, created: time.Now()}
+	return options, nil
+}
+
+// Log in to Bluesky, refreshing the stored session if there is one.
+// Refresh tokens can only be used once, so the new one is written to the credentials file.
+func prepareBluesky(destination platforms.Destination) (platforms.BlueskySession, error) {
+	bluesky, ok := destination.(*platforms.Bluesky)
+	if !ok {
+		return platforms.BlueskySession{}, fmt.Errorf("failed to assert that destination is Bluesky")
+	}
+	session, err := bluesky.Authorize(internal.CredentialViper.GetString("bluesky_app_password"), internal.CredentialViper.GetString("bluesky_refresh_token"))
+	if err != nil {
+		return platforms.BlueskySession{}, err
+	}
+	internal.CredentialViper.Set("bluesky_refresh_token", session.RefreshJwt)
+	if err := internal.CredentialViper.WriteConfig(); err != nil {
+		// The session still works, but the app password will be needed next time
+		log.Warn("Failed to write Bluesky refresh token to the credentials file", "error", err)
+	}
+	return session, nil
+}
+
+// Return the options needed to pull from a source.
+// For Blogger, this authorizes and looks up the blog ID.
+func sourceOptions(source platforms.Source) (platforms.PushPullOptions, error) {
+	switch source.GetType() {
+	case "blogger":
+		_, accessToken, blogId, refreshToken, err := prepareBlogger(source, nil, internal.CredentialViper.GetString("google_client_id"), internal.CredentialViper.GetString("google_client_secret"), internal.CredentialViper.GetString("google_refresh_token"))
+		if err != nil {
+			return platforms.PushPullOptions{}, err
+		}
+		return platforms.PushPullOptions{
+			AccessToken: accessToken,
+			BlogId:      blogId,
+			// Credentials for getting a new access token if the current one expires
+			RefreshToken: refreshToken,
+			ClientId:     internal.CredentialViper.GetString("google_client_id"),
+			ClientSecret: internal.CredentialViper.GetString("google_client_secret"),
+			LlmProvider:  internal.CredentialViper.GetString("llm_provider"),
+			LlmBaseUrl:   internal.CredentialViper.GetString("llm_base_url"),
+			LlmApiKey:    internal.CredentialViper.GetString("llm_api_key"),
+			LlmModel:     internal.CredentialViper.GetString("llm_model"),
+		}, nil
+	case "blogger-export":
+		// Exports are read offline, so only the LLM details are needed
+		return platforms.PushPullOptions{
+			LlmProvider: internal.CredentialViper.GetString("llm_provider"),
+			LlmBaseUrl:  internal.CredentialViper.GetString("llm_base_url"),
+			LlmApiKey:   internal.CredentialViper.GetString("llm_api_key"),
+			LlmModel:    internal.CredentialViper.GetString("llm_model"),
+		}, nil
+	default:
+		return platforms.PushPullOptions{}, nil
+	}
+}
+
+// Set the option that tells the source which post to pull: the post's URL, or its file for Markdown
+func setSpecifier(source platforms.Source, options *platforms.PushPullOptions, specifier string) {
+	switch source.GetType() {
+	case "blogger", "blogger-export", "wxr":
+		options.PostUrl = specifier
+	case "markdown":
+		options.Filepath = specifier
+	}
+}
+
+// If the source can list its posts and a destination rewrites internal links, build an index of the source's posts.
+// Failing to build the index isn't fatal; links are just left untouched.
+func buildLinkIndex(source platforms.Source, options platforms.PushPullOptions, destinationSlice []platforms.Destination) *platforms.LinkIndex {
+	indexable, ok := source.(platforms.IndexableSource)
+	if !ok {
+		return nil
+	}
+	needed := false
+	for _, destination := range destinationSlice {
+		if rewriter, ok := destination.(platforms.LinkRewriter); ok && rewriter.RewritesLinks() {
+			needed = true
+			break
+		}
+	}
+	if !needed {
+		return nil
+	}
+	index, err := indexable.BuildLinkIndex(options)
+	if err != nil {
+		log.Error("Failed to build link index; internal links will not be rewritten", "source", source.GetName(), "error", err)
+		return nil
+	}
+	log.Debug("Built link index", "source", source.GetName(), "posts", len(index.Slugs))
+	return &index
+}
+
+// Batches of Git changes for Markdown destinations, keyed by destination name
+type gitBatches map[string]*platforms.GitBatch
+
+// Begin a Git batch for each Markdown destination with a Git directory.
+// If always is false, only destinations with batching enabled in their config get a batch.
+func beginGitBatches(destinationSlice []platforms.Destination, always bool) (gitBatches, error) {
+	batches := gitBatches{}
+	for _, destination := range destinationSlice {
+		markdownDest, ok := destination.(*platforms.Markdown)
+		if !ok || markdownDest.GitDir == "" || !(always || markdownDest.Git.Batch) {
+			continue
+		}
+		batch, err := markdownDest.BeginBatch(markdownOptions())
+		if err != nil {
+			return nil, err
+		}
+		batches[destination.GetName()] = batch
+	}
+	return batches, nil
+}
+
+// Commit and push every batch
+func (b gitBatches) commit(destinationSlice []platforms.Destination) error {
+	for _, destination := range destinationSlice {
+		batch, ok := b[destination.GetName()]
+		if !ok {
+			continue
+		}
+		markdownDest := destination.(*platforms.Markdown)
+		changed := batch.Len()
+		commitHash, err := markdownDest.CommitBatch(batch, true, markdownOptions())
+		if err != nil {
+			return err
+		}
+		if changed > 0 {
+			log.Info("Committed and pushed changes", "destination", destination.GetName(), "hash", commitHash, "posts", changed)
+		}
+	}
+	return nil
+}
+
+// Return the runtime options for Markdown destinations
+func markdownOptions() platforms.PushPullOptions {
+	return platforms.PushPullOptions{
+		// Used to open pull requests if the destination is in review mode
+		ForgeToken: internal.CredentialViper.GetString("forge_token"),
+		// Used to pull, push, and sign commits if git_dir is set
+		GitToken:             internal.CredentialViper.GetString("git_token"),
+		GitSshPassphrase:     internal.CredentialViper.GetString("git_ssh_passphrase"),
+		GitSigningPassphrase: internal.CredentialViper.GetString("git_signing_passphrase"),
+	}
+}
+
 // For each destination, push the data
-func pushToDestinations(postData platforms.PostData, destinationSlice []platforms.Destination, dryRun bool) error {
+// If links is not nil, destinations that support it rewrite links to other posts on the source
+// If batches has a batch for a Markdown destination, the change is added to it rather than committed
+// If a destination's pre_push or pre_commit hook aborts the push, the other destinations are still pushed to, and an error wrapping platforms.ErrPrePushAborted is returned at the end
+func pushToDestinations(postData platforms.PostData, destinationSlice []platforms.Destination, links *platforms.LinkIndex, batches gitBatches, dryRun bool) error {
+	var aborted []error
 	for _, destination := range destinationSlice {
-		var found bool = true
-		var options platforms.PushPullOptions
-		switch destination.GetType() {
-		case "markdown":
-			// No runtime options for Markdown
-			// Filepath is generated from by turning the title into a URL-friendly slug
-			// The content directory is part of the Markdown struct
-			options = platforms.PushPullOptions{}
-
-		case "blogger":
-			_, accessToken, blogId, _, err := prepareBlogger(nil, destination, internal.CredentialViper.GetString("google_client_id"), internal.CredentialViper.GetString("google_client_secret"), internal.CredentialViper.GetString("google_refresh_rtoken"))
-			if err != nil {
-				return err
-			}
-			options = platforms.PushPullOptions{
-				AccessToken: accessToken,
-				BlogId:      blogId,
-			}
-		default:
-			found = false
+		postData, err := preparePost(postData, destination, links)
+		if err != nil {
+			return err
+		}
+		options, found, err := destinationOptions(destination, batches)
+		if err != nil {
+			return err
 		}
 		if found {
 			// Check if this is a dry run
@@ -196,14 +366,135 @@ func pushToDestinations(postData platforms.PostData, destinationSlice []platform
 				log.Info("Skipping push due to dry run")
 				continue
 			}
+			hookable, hasHooks := destination.(platforms.HookableDestination)
+			if hasHooks {
+				hooked, err := hookable.RunPrePush(destination, postData)
+				if err != nil {
+					log.Error("Not pushing post", append(platforms.LogFields(postData), "destination", destination.GetName(), "error", err)...)
+					platforms.PushesTotal.WithLabelValues(destination.GetName(), destination.GetType(), "aborted").Inc()
+					aborted = append(aborted, fmt.Errorf("%s: %w", destination.GetName(), err))
+					continue
+				}
+				postData = hooked
+			}
+			start := time.Now()
 			err := destination.Push(postData, options)
+			platforms.ObservePush(destination, start, err)
+			if auditErr := platforms.Audit.RecordPush(destination, postData, err); auditErr != nil {
+				log.Error("Failed to write to the audit log", "error", auditErr)
+			}
+			if errors.Is(err, platforms.ErrPrePushAborted) {
+				log.Error("Not pushing post", append(platforms.LogFields(postData), "destination", destination.GetName(), "error", err)...)
+				aborted = append(aborted, fmt.Errorf("%s: %w", destination.GetName(), err))
+				continue
+			}
 			if err != nil {
 				return err
 			}
+			log.Info("Pushed post", append(platforms.LogFields(postData), "destination", destination.GetName(), "duration", time.Since(start))...)
+			if hasHooks {
+				// The post was already pushed, so a failing post_push hook doesn't stop anything
+				if err := hookable.RunPostPush(destination, postData); err != nil {
+					log.Error("post_push hook failed", append(platforms.LogFields(postData), "destination", destination.GetName(), "error", err)...)
+				}
+			}
 		} else {
 			log.Error("Destination type not implemented", "type", destination.GetType())
 		}
 	}
-	// This should never be reached unless there are no destinations
-	return nil
+	return errors.Join(aborted...)
+}
+
+// Return the post as it would be pushed to the destination, with links rewritten and the destination's transforms applied.
+// The destination gets its own copy of the post so changes don't carry over to other destinations.
+func preparePost(postData platforms.PostData, destination platforms.Destination, links *platforms.LinkIndex) (platforms.PostData, error) {
+	if rewriter, ok := destination.(platforms.LinkRewriter); ok && links != nil && rewriter.RewritesLinks() {
+		var unresolved []string
+		postData, unresolved = rewriter.RewriteLinks(postData, *links)
+		for _, link := range unresolved {
+			log.Warn("Internal link could not be resolved", "destination", destination.GetName(), "title", postData.Title, "link", link)
+		}
+	}
+	// Run the destination's transforms, if it has any
+	if transformable, ok := destination.(platforms.TransformableDestination); ok {
+		var err error
+		postData, err = transformable.ApplyTransforms(postData)
+		if err != nil {
+			return platforms.PostData{}, fmt.Errorf("failed to transform post for %s: %w", destination.GetName(), err)
+		}
+	}
+	return postData, nil
+}
+
+// Return the options needed to push to (or delete from) a destination.
+// If the destination type isn't implemented, found is false.
+// If batches has a batch for a Markdown destination, the options add changes to it rather than committing them.
+func destinationOptions(destination platforms.Destination, batches gitBatches) (options platforms.PushPullOptions, found bool, err error) {
+	switch destination.GetType() {
+	case "markdown":
+		// The Git and forge credentials, and the batch if commits to this destination are being batched
+		// The file path is made from the title and the content directory is part of the Markdown struct, so neither is needed here
+		options = markdownOptions()
+		options.GitBatch = batches[destination.GetName()]
+
+	case "blogger":
+		// Authorizing may open a browser, so it's only done once for every push to the destination
+		options, err = cachedCredentials(destination, func() (platforms.PushPullOptions, error) {
+			_, accessToken, blogId, _, err := prepareBlogger(nil, destination, internal.CredentialViper.GetString("google_client_id"), internal.CredentialViper.GetString("google_client_secret"), internal.CredentialViper.GetString("google_refresh_token"))
+			if err != nil {
+				return platforms.PushPullOptions{}, err
+			}
+			return platforms.PushPullOptions{
+				AccessToken: accessToken,
+				BlogId:      blogId,
+			}, nil
+		})
+		if err != nil {
+			return platforms.PushPullOptions{}, false, err
+		}
+	case "wxr":
+		// The file is part of the Wxr struct, so there are no runtime options
+	case "feed":
+		// Only needed if the feed is committed to a Git repository
+		options = markdownOptions()
+	case "mastodon":
+		options = platforms.PushPullOptions{
+			MastodonToken: internal.CredentialViper.GetString("mastodon_token"),
+		}
+	case "bluesky":
+		// Refresh tokens can only be used once, so the session is shared by every push to the destination
+		options, err = cachedCredentials(destination, func() (platforms.PushPullOptions, error) {
+			session, err := prepareBluesky(destination)
+			if err != nil {
+				return platforms.PushPullOptions{}, err
+			}
+			return platforms.PushPullOptions{
+				BlueskyAccessToken: session.AccessJwt,
+				BlueskyDid:         session.Did,
+			}, nil
+		})
+		if err != nil {
+			return platforms.PushPullOptions{}, false, err
+		}
+	case "hashnode":
+		options = platforms.PushPullOptions{
+			HashnodeToken: internal.CredentialViper.GetString("hashnode_token"),
+		}
+	case "medium":
+		options = platforms.PushPullOptions{
+			MediumToken: internal.CredentialViper.GetString("medium_token"),
+		}
+	case "micropub":
+		options = platforms.PushPullOptions{
+			MicropubToken: internal.CredentialViper.GetString("micropub_token"),
+		}
+	case "webhook":
+		// Each webhook can have its own secret
+		options = platforms.PushPullOptions{
+			WebhookSecret: internal.CredentialViper.GetString("webhook_secrets." + destination.GetName()),
+		}
+	default:
+		return platforms.PushPullOptions{}, false, nil
+	}
+	return options, true, nil
 }
@@ -49,5 +49,13 @@ func init() {
 	internal.CredentialViper.BindPFlag("log_level", RootCmd.PersistentFlags().Lookup("log-level"))
 	internal.ConfigViper.BindEnv("log_level", "CROSS_BLOGGER_LOG_LEVEL")
 	internal.ConfigViper.SetDefault("log_level", "info")
+	// Log format
+	RootCmd.PersistentFlags().String("log-format", "", "Set the log format (\"text\", \"json\", or \"logfmt\")")
+	internal.ConfigViper.BindPFlag("log_format", RootCmd.PersistentFlags().Lookup("log-format"))
+	internal.ConfigViper.BindEnv("log_format", "CROSS_BLOGGER_LOG_FORMAT")
+	internal.ConfigViper.SetDefault("log_format", "text")
+	// Audit log
+	RootCmd.PersistentFlags().String("audit-log", "", "Append a JSON Lines record of every push, update, and delete to this file")
+	internal.ConfigViper.BindPFlag("audit_log", RootCmd.PersistentFlags().Lookup("audit-log"))
 
 }
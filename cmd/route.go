@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/slashtechno/cross-blogger/internal"
+	"github.com/slashtechno/cross-blogger/internal/platforms"
+	"github.com/spf13/cobra"
+)
+
+var routeCmd = &cobra.Command{
+	Use:   "route",
+	Short: "Inspect the routing rules",
+	Long: `Inspect the routing rules in the config file.
+	Routes decide which destinations each post is pushed to when watching, importing, or serving a source.`,
+}
+
+var routeExplainCmd = &cobra.Command{
+	Use:   "explain",
+	Short: "Show which routes match a post",
+	Long: `Pull a post and show which routes match it and the destinations it would be pushed to.
+	Specify the source with the first positional argument.
+	The second positional argument is the specifier, such as a Blogger post URL or a file path, the same as with publish.`,
+	// Arg 1: Source
+	// Arg 2: Specifier
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		routes, err := loadRoutes()
+		if err != nil {
+			log.Fatal(err)
+		}
+		sourceSlice, _, err := platforms.Load(internal.ConfigViper.Get("sources"), internal.ConfigViper.Get("destinations"), []string{args[0]}, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var source platforms.Source
+		for _, s := range sourceSlice {
+			if s.GetName() == args[0] {
+				source = s
+				break
+			}
+		}
+		if source == nil {
+			log.Fatal("Source not found", "source", args[0])
+		}
+		options, err := sourceOptions(source)
+		if err != nil {
+			log.Fatal(err)
+		}
+		setSpecifier(source, &options, args[1])
+		postData, err := source.Pull(options)
+		if err != nil {
+			log.Fatal(err)
+		}
+		postData.Source = source.GetName()
+		writeRouteExplanation(cmd.OutOrStdout(), routes, postData, platforms.CategoryPrefix(source))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(routeCmd)
+	routeCmd.AddCommand(routeExplainCmd)
+}
+
+// Load the routes from the config file and check that the destinations they use are configured
+func loadRoutes() (platforms.Routes, error) {
+	routes, err := platforms.RoutesFromInterface(internal.ConfigViper.Get("routes"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid routes: %w", err)
+	}
+	names := []string{}
+	if destinations, ok := internal.ConfigViper.Get("destinations").([]interface{}); ok {
+		for _, dest := range destinations {
+			if destMap, ok := dest.(map[string]interface{}); ok {
+				if name, ok := destMap["name"].(string); ok {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	if err := routes.Validate(names); err != nil {
+		return nil, fmt.Errorf("invalid routes: %w", err)
+	}
+	return routes, nil
+}
+
+// Only keep the destinations the routes send the post to, logging if there aren't any
+func routeDestinations(routes platforms.Routes, postData platforms.PostData, source platforms.Source, destinationSlice []platforms.Destination) []platforms.Destination {
+	routed := routes.Filter(postData, platforms.CategoryPrefix(source), destinationSlice)
+	if len(routed) == 0 {
+		log.Info("No routes send the post to these destinations; not pushing it", append(platforms.LogFields(postData), "title", postData.Title)...)
+	} else if len(routed) < len(destinationSlice) {
+		names := []string{}
+		for _, destination := range routed {
+			names = append(names, destination.GetName())
+		}
+		log.Debug("Routed post", append(platforms.LogFields(postData), "destinations", strings.Join(names, ", "))...)
+	}
+	return routed
+}
+
+// Write each route's decision for the post and the destinations it would be pushed to
+func writeRouteExplanation(w io.Writer, routes platforms.Routes, postData platforms.PostData, categoryPrefix string) {
+	fmt.Fprintf(w, "Post: %s\n", postData.Title)
+	fmt.Fprintf(w, "  source: %s\n", postData.Source)
+	fmt.Fprintf(w, "  categories: %s\n", strings.Join(postData.Categories, ", "))
+	fmt.Fprintf(w, "  tags: %s\n", strings.Join(postData.Tags, ", "))
+	fmt.Fprintf(w, "  draft: %t\n\n", postData.Draft)
+	if len(routes) == 0 {
+		fmt.Fprintln(w, "No routes are configured, so the post is pushed to every destination given to watch, import, or serve.")
+		return
+	}
+	destinations, decisions := routes.Evaluate(postData, categoryPrefix)
+	for _, decision := range decisions {
+		switch {
+		case decision.Skipped:
+			fmt.Fprintf(w, "[skipped] %s: an earlier route stopped evaluation\n", decision.Route.Name)
+			continue
+		case decision.Matched:
+			fmt.Fprintf(w, "[matched] %s -> %s", decision.Route.Name, strings.Join(decision.Route.Destinations, ", "))
+			if len(decision.Route.Destinations) == 0 {
+				fmt.Fprint(w, "(no destinations)")
+			}
+			if decision.Route.Stop {
+				fmt.Fprint(w, " (stop)")
+			}
+			fmt.Fprintln(w)
+		default:
+			fmt.Fprintf(w, "[no match] %s\n", decision.Route.Name)
+		}
+		for _, reason := range decision.Reasons {
+			fmt.Fprintf(w, "    %s\n", reason)
+		}
+	}
+	if len(destinations) == 0 {
+		fmt.Fprintln(w, "\nDestinations: none; the post isn't pushed anywhere")
+		return
+	}
+	fmt.Fprintf(w, "\nDestinations: %s\n", strings.Join(destinations, ", "))
+}
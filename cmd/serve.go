@@ -0,0 +1,636 @@
+package cmd
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/slashtechno/cross-blogger/internal"
+	"github.com/slashtechno/cross-blogger/internal/platforms"
+	"github.com/spf13/cobra"
+)
+
+// GitHub sends payloads of up to 25 MB
+const maxHookBodySize = 25 << 20
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run an HTTP server that publishes posts when it receives webhooks",
+	Long: `Run an HTTP server that publishes posts when it receives webhooks.
+	Hooks are configured in the serve table of the config file and are served at /hooks/<name>.
+	A "github" hook publishes the Markdown files changed by a push to a Markdown source's repository.
+	A "publish" hook publishes the post at the URL (or file path) in the request.
+	Jobs are run in the background, and their status can be checked at /jobs and /jobs/<id>.
+	If api_token is set in the credentials file, posts can also be published and previewed through the management API.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		hooks, err := serveHooksFromInterface(internal.ConfigViper.Get("serve.hooks"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		apiToken := internal.CredentialViper.GetString("api_token")
+		if len(hooks) == 0 && apiToken == "" {
+			log.Fatal("No hooks are configured in the serve table of the config file and api_token isn't set, so there's nothing to serve")
+		}
+		// Load every source and destination so hooks can use any of them
+		sourceSlice, destinationSlice, err := platforms.Load(internal.ConfigViper.Get("sources"), internal.ConfigViper.Get("destinations"), nil, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s, err := newServer(hooks, sourceSlice, destinationSlice)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s.apiToken = apiToken
+		// Routes narrow down which of a job's destinations each post is pushed to, the same as with watch
+		s.postRoutes, err = loadRoutes()
+		if err != nil {
+			log.Fatal(err)
+		}
+		s.stuckAfter = internal.ConfigViper.GetDuration("serve.stuck_after")
+		s.jobs = newJobQueue(internal.ConfigViper.GetInt("serve.concurrency"), internal.ConfigViper.GetInt("serve.queue_size"), internal.ConfigViper.GetInt("serve.history"), s.runJob)
+
+		httpServer := &http.Server{
+			Addr:              internal.ConfigViper.GetString("serve.address"),
+			Handler:           s.routes(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			log.Info("Shutting down; waiting for queued jobs to finish")
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := httpServer.Shutdown(shutdownCtx); err != nil {
+				log.Error("Failed to shut down the server", "error", err)
+			}
+		}()
+		log.Info("Listening for webhooks", "address", httpServer.Addr, "hooks", len(hooks), "api", apiToken != "")
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+		s.jobs.close()
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Dry run - pull posts when hooks are received but don't push them")
+	serveCmd.Flags().String("address", ":8080", "Address to listen on")
+	serveCmd.Flags().Int("concurrency", 2, "Number of jobs to run at once")
+	serveCmd.Flags().Int("queue-size", 100, "Number of jobs that can wait to be run before hooks are rejected")
+	serveCmd.Flags().Int("history", 100, "Number of finished jobs to remember")
+	serveCmd.Flags().Duration("stuck-after", 30*time.Minute, "How long a job can run before /healthz reports it as stuck (0 to never)")
+	internal.ConfigViper.BindPFlag("serve.address", serveCmd.Flags().Lookup("address"))
+	internal.ConfigViper.BindPFlag("serve.concurrency", serveCmd.Flags().Lookup("concurrency"))
+	internal.ConfigViper.BindPFlag("serve.queue_size", serveCmd.Flags().Lookup("queue-size"))
+	internal.ConfigViper.BindPFlag("serve.history", serveCmd.Flags().Lookup("history"))
+	internal.ConfigViper.BindPFlag("serve.stuck_after", serveCmd.Flags().Lookup("stuck-after"))
+}
+
+// A serveHook publishes posts when a request is sent to /hooks/<Name>
+type serveHook struct {
+	Name string
+	// Type is "github" or "publish"
+	Type         string
+	Source       string
+	Destinations []string
+	// Branch, for GitHub hooks, is the only branch whose pushes are published. If empty, pushes to any branch are.
+	Branch string
+	// Secret is used to check the signature of requests, and can be sent as a bearer token to publish hooks
+	Secret string
+}
+
+// Convert the hooks in the serve table (interface{} due to how Viper works) to serveHooks
+func serveHooksFromInterface(h interface{}) ([]serveHook, error) {
+	if h == nil {
+		return nil, nil
+	}
+	hookSlice, ok := h.([]interface{})
+	if !ok {
+		return nil, errors.New("serve.hooks is not a list of tables")
+	}
+	hooks := []serveHook{}
+	names := map[string]bool{}
+	for _, hookInterface := range hookSlice {
+		hookMap, ok := hookInterface.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("failed to convert hook to map")
+		}
+		hook := serveHook{}
+		hook.Name, _ = hookMap["name"].(string)
+		if hook.Name == "" {
+			return nil, errors.New("name is required for hooks")
+		}
+		if names[hook.Name] {
+			return nil, fmt.Errorf("there is more than one hook named %s", hook.Name)
+		}
+		names[hook.Name] = true
+		hook.Type, _ = hookMap["type"].(string)
+		switch hook.Type {
+		case "github", "publish":
+		default:
+			return nil, fmt.Errorf("unknown hook type for %s: %q", hook.Name, hook.Type)
+		}
+		hook.Source, _ = hookMap["source"].(string)
+		if hook.Source == "" {
+			return nil, fmt.Errorf("source is required for hook %s", hook.Name)
+		}
+		destinations, ok := hookMap["destinations"].([]interface{})
+		if !ok || len(destinations) == 0 {
+			return nil, fmt.Errorf("destinations is required for hook %s", hook.Name)
+		}
+		for _, destination := range destinations {
+			name, ok := destination.(string)
+			if !ok {
+				return nil, fmt.Errorf("destinations for hook %s should be strings", hook.Name)
+			}
+			hook.Destinations = append(hook.Destinations, name)
+		}
+		hook.Branch, _ = hookMap["branch"].(string)
+		// Unauthenticated hooks would let anyone publish, so a secret is required
+		hook.Secret = internal.CredentialViper.GetString("hook_secrets." + hook.Name)
+		if hook.Secret == "" {
+			return nil, fmt.Errorf("hook %s has no secret; set hook_secrets.%s in the credentials file", hook.Name, hook.Name)
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, nil
+}
+
+// server receives hooks and management API requests and runs the jobs they create
+type server struct {
+	hooks        map[string]serveHook
+	sources      map[string]platforms.Source
+	destinations map[string]platforms.Destination
+	// The slices keep the order of the config file for listing
+	sourceSlice      []platforms.Source
+	destinationSlice []platforms.Destination
+	jobs             *jobQueue
+	// apiToken is the bearer token for the management API
+	apiToken string
+	// postRoutes decide which of a job's destinations its post is pushed to
+	postRoutes platforms.Routes
+	// stuckAfter is how long a job can run before the health check fails
+	stuckAfter time.Duration
+	// Jobs run concurrently, so each destination (and source repository) is only used by one job at a time
+	locks     map[string]*sync.Mutex
+	locksLock sync.Mutex
+}
+
+// Check that the sources and destinations of every hook exist
+func newServer(hooks []serveHook, sourceSlice []platforms.Source, destinationSlice []platforms.Destination) (*server, error) {
+	s := &server{
+		hooks:            map[string]serveHook{},
+		sources:          map[string]platforms.Source{},
+		destinations:     map[string]platforms.Destination{},
+		locks:            map[string]*sync.Mutex{},
+		sourceSlice:      sourceSlice,
+		destinationSlice: destinationSlice,
+	}
+	for _, source := range sourceSlice {
+		s.sources[source.GetName()] = source
+	}
+	for _, destination := range destinationSlice {
+		s.destinations[destination.GetName()] = destination
+	}
+	for _, hook := range hooks {
+		source, ok := s.sources[hook.Source]
+		if !ok {
+			return nil, fmt.Errorf("source %s of hook %s not found", hook.Source, hook.Name)
+		}
+		if hook.Type == "github" && source.GetType() != "markdown" {
+			return nil, fmt.Errorf("hook %s is a GitHub hook, so its source should be Markdown", hook.Name)
+		}
+		for _, destination := range hook.Destinations {
+			if _, ok := s.destinations[destination]; !ok {
+				return nil, fmt.Errorf("destination %s of hook %s not found", destination, hook.Name)
+			}
+		}
+		s.hooks[hook.Name] = hook
+	}
+	return s, nil
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /hooks/{name}", s.handleHook)
+	// Management API
+	mux.HandleFunc("GET /sources", s.requireToken(true, s.handleSources))
+	mux.HandleFunc("GET /destinations", s.requireToken(true, s.handleDestinations))
+	mux.HandleFunc("POST /publish", s.requireToken(false, s.handleApiPublish))
+	mux.HandleFunc("POST /preview", s.requireToken(false, s.handlePreview))
+	mux.HandleFunc("GET /jobs", s.requireToken(true, s.handleJobs))
+	mux.HandleFunc("GET /jobs/{id}", s.requireToken(true, s.handleJob))
+	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	return mux
+}
+
+// Respond with 200 and the state of the job queue, or 503 if the queue is full or a job has been running for longer than serve.stuck_after
+func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	health := s.jobs.health(s.stuckAfter)
+	if !health.Healthy() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{"status": "unhealthy", "jobs": health})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "jobs": health})
+}
+
+// Return the lock for a destination or source
+func (s *server) lock(name string) *sync.Mutex {
+	s.locksLock.Lock()
+	defer s.locksLock.Unlock()
+	if s.locks[name] == nil {
+		s.locks[name] = &sync.Mutex{}
+	}
+	return s.locks[name]
+}
+
+// Pull the job's post and push it to each of its destinations.
+// A failure on one destination doesn't stop the others.
+func (s *server) runJob(j job) (string, error) {
+	source := s.sources[j.Source]
+	options, err := sourceOptions(source)
+	if err != nil {
+		return "", err
+	}
+	// The source is locked while it's read so another job can't update it (and change the files or branch) at the same time
+	lock := s.lock("source:" + source.GetName())
+	lock.Lock()
+	postData, err := s.pullJobPost(source, options, j)
+	if err != nil {
+		lock.Unlock()
+		return "", err
+	}
+	log.Info("Publishing post", append(platforms.LogFields(postData), "job", j.Id, "trigger", j.Trigger, "title", postData.Title)...)
+
+	destinationSlice := []platforms.Destination{}
+	for _, name := range j.Destinations {
+		destinationSlice = append(destinationSlice, s.destinations[name])
+	}
+	destinationSlice = routeDestinations(s.postRoutes, postData, source, destinationSlice)
+	links := buildLinkIndex(source, options, destinationSlice)
+	lock.Unlock()
+	var errs []error
+	for _, destination := range destinationSlice {
+		lock := s.lock("destination:" + destination.GetName())
+		lock.Lock()
+		err := pushToDestinations(postData, []platforms.Destination{destination}, links, nil, dryRun)
+		lock.Unlock()
+		if err != nil {
+			log.Error("Failed to publish post", append(platforms.LogFields(postData), "job", j.Id, "destination", destination.GetName(), "error", err)...)
+			errs = append(errs, fmt.Errorf("%s: %w", destination.GetName(), err))
+		}
+	}
+	return postData.Title, errors.Join(errs...)
+}
+
+// Pull the job's post, first updating a Markdown source's repository if the job asks for it.
+// The caller should hold the source's lock.
+func (s *server) pullJobPost(source platforms.Source, options platforms.PushPullOptions, j job) (platforms.PostData, error) {
+	if markdownSource, ok := source.(*platforms.Markdown); ok && j.updateSource {
+		if err := markdownSource.UpdateFromRemote(markdownOptions()); err != nil {
+			return platforms.PostData{}, fmt.Errorf("failed to update %s: %w", source.GetName(), err)
+		}
+	}
+	specifier, err := postPathInContent(source, j.Specifier)
+	if err != nil {
+		return platforms.PostData{}, err
+	}
+	setSpecifier(source, &options, specifier)
+	postData, err := source.Pull(options)
+	if err != nil {
+		return platforms.PostData{}, err
+	}
+	postData.Source = source.GetName()
+	return postData, nil
+}
+
+// Check the request's signature (or, for publish hooks, bearer token) and create jobs from it
+func (s *server) handleHook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := s.hooks[r.PathValue("name")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "hook not found")
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHookBodySize))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, "the body is too large")
+		return
+	}
+	switch hook.Type {
+	case "github":
+		if !validSignature(r.Header.Get("X-Hub-Signature-256"), body, hook.Secret) {
+			writeError(w, http.StatusUnauthorized, "invalid signature")
+			return
+		}
+		s.handleGitHub(w, r, hook, body)
+	case "publish":
+		if !validSignature(r.Header.Get(platforms.WebhookSignatureHeader), body, hook.Secret) && !validBearer(r.Header.Get("Authorization"), hook.Secret) {
+			writeError(w, http.StatusUnauthorized, "invalid signature or token")
+			return
+		}
+		s.handlePublish(w, hook, body)
+	}
+}
+
+// The parts of a GitHub push event that are used
+type gitHubPush struct {
+	Ref     string `json:"ref"`
+	Deleted bool   `json:"deleted"`
+	Commits []struct {
+		Added    []string `json:"added"`
+		Modified []string `json:"modified"`
+		Removed  []string `json:"removed"`
+	} `json:"commits"`
+}
+
+// Publish the Markdown files in the source's content directory that were added or changed by a push
+func (s *server) handleGitHub(w http.ResponseWriter, r *http.Request, hook serveHook, body []byte) {
+	switch event := r.Header.Get("X-GitHub-Event"); event {
+	case "ping":
+		writeJSON(w, http.StatusOK, map[string]string{"message": "pong"})
+		return
+	case "push":
+	default:
+		writeJSON(w, http.StatusOK, map[string]string{"message": "ignored " + event + " event"})
+		return
+	}
+	var push gitHubPush
+	if err := json.Unmarshal(body, &push); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid push event: "+err.Error())
+		return
+	}
+	if push.Deleted || (hook.Branch != "" && push.Ref != "refs/heads/"+hook.Branch) {
+		writeJSON(w, http.StatusOK, map[string]string{"message": "ignored push to " + push.Ref})
+		return
+	}
+
+	// Work out which files exist after the push, in the order they were first changed
+	changed := []string{}
+	exists := map[string]bool{}
+	for _, commit := range push.Commits {
+		for _, file := range append(append([]string{}, commit.Added...), commit.Modified...) {
+			if _, seen := exists[file]; !seen {
+				changed = append(changed, file)
+			}
+			exists[file] = true
+		}
+		for _, file := range commit.Removed {
+			exists[file] = false
+		}
+	}
+	source := s.sources[hook.Source].(*platforms.Markdown)
+	contentPath := repositoryContentPath(source)
+	jobs := []job{}
+	for _, file := range changed {
+		if !exists[file] {
+			continue
+		}
+		specifier, ok := postFileInContent(file, contentPath)
+		if !ok {
+			continue
+		}
+		jobs = append(jobs, job{
+			Trigger:      hook.Name,
+			Source:       hook.Source,
+			Specifier:    specifier,
+			Destinations: hook.Destinations,
+			updateSource: true,
+		})
+	}
+	// Either every post is queued or none are, so a redelivered push doesn't publish some posts twice
+	jobs, err := s.jobs.enqueueAll(jobs)
+	if err != nil {
+		log.Error("Failed to queue jobs", "hook", hook.Name, "ref", push.Ref, "error", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	log.Info("Received push", "hook", hook.Name, "ref", push.Ref, "jobs", len(jobs))
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"jobs": jobs})
+}
+
+// Return the content directory of a Markdown source relative to the root of its repository, using forward slashes like GitHub.
+// If git_dir isn't set, content_dir is assumed to already be relative to the root.
+func repositoryContentPath(source *platforms.Markdown) string {
+	contentDir := filepath.Clean(source.ContentDir)
+	if source.GitDir != "" {
+		gitDir, errGit := filepath.Abs(source.GitDir)
+		absContentDir, errContent := filepath.Abs(contentDir)
+		if errGit == nil && errContent == nil {
+			if relative, err := filepath.Rel(gitDir, absContentDir); err == nil {
+				contentDir = relative
+			}
+		}
+	}
+	return filepath.ToSlash(contentDir)
+}
+
+// If the file in the repository is a post in the content directory, return its path relative to the content directory.
+// Files starting with an underscore, such as Hugo's _index.md, aren't posts.
+func postFileInContent(file string, contentPath string) (string, bool) {
+	extension := path.Ext(file)
+	if extension != ".md" && extension != ".markdown" {
+		return "", false
+	}
+	if strings.HasPrefix(path.Base(file), "_") {
+		return "", false
+	}
+	if contentPath == "." {
+		return filepath.FromSlash(file), true
+	}
+	relative, found := strings.CutPrefix(file, contentPath+"/")
+	if !found {
+		return "", false
+	}
+	return filepath.FromSlash(relative), true
+}
+
+// Check that a post path from a request is relative and doesn't leave the directory it's in
+func checkPostPath(specifier string) error {
+	if filepath.IsAbs(specifier) || filepath.VolumeName(specifier) != "" || strings.HasPrefix(specifier, "/") {
+		return errors.New("the path must be relative to the source's content directory")
+	}
+	for _, part := range strings.Split(filepath.ToSlash(specifier), "/") {
+		if part == ".." {
+			return errors.New("the path can't contain ..")
+		}
+	}
+	return nil
+}
+
+// For Markdown sources, make sure the post requested over HTTP is a file in the content directory and return its cleaned path.
+// Pull falls back to treating a path as a normal path, so without this any file on the server could be published.
+// Other sources' specifiers are returned unchanged.
+func postPathInContent(source platforms.Source, specifier string) (string, error) {
+	markdownSource, ok := source.(*platforms.Markdown)
+	if !ok {
+		return specifier, nil
+	}
+	if err := checkPostPath(specifier); err != nil {
+		return "", err
+	}
+	cleaned := filepath.Clean(filepath.FromSlash(specifier))
+	contentDir, err := filepath.EvalSymlinks(markdownSource.ContentDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the content directory: %w", err)
+	}
+	// Symlinks in the content directory could still point outside of it
+	resolved, err := filepath.EvalSymlinks(filepath.Join(contentDir, cleaned))
+	if err != nil {
+		return "", fmt.Errorf("post not found in the content directory: %s", specifier)
+	}
+	relative, err := filepath.Rel(contentDir, resolved)
+	if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("post not found in the content directory: %s", specifier)
+	}
+	return cleaned, nil
+}
+
+// The body of a request to a publish hook
+type publishRequest struct {
+	// Url is the post to publish. Path can be used instead for Markdown sources.
+	Url  string `json:"url"`
+	Path string `json:"path"`
+	// Destinations limits which of the hook's destinations the post is published to
+	Destinations []string `json:"destinations"`
+}
+
+// Publish the post in the request
+func (s *server) handlePublish(w http.ResponseWriter, hook serveHook, body []byte) {
+	var request publishRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	specifier := request.Url
+	if specifier == "" {
+		specifier = request.Path
+	}
+	if specifier == "" {
+		writeError(w, http.StatusBadRequest, "url or path is required")
+		return
+	}
+	if _, markdown := s.sources[hook.Source].(*platforms.Markdown); markdown {
+		if err := checkPostPath(specifier); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	destinations := hook.Destinations
+	if len(request.Destinations) > 0 {
+		for _, requested := range request.Destinations {
+			allowed := false
+			for _, destination := range hook.Destinations {
+				if requested == destination {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("%s isn't one of the hook's destinations", requested))
+				return
+			}
+		}
+		destinations = request.Destinations
+	}
+	queued, err := s.jobs.enqueue(job{
+		Trigger:      hook.Name,
+		Source:       hook.Source,
+		Specifier:    specifier,
+		Destinations: destinations,
+	})
+	if errors.Is(err, errQueueFull) {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Info("Queued post", "hook", hook.Name, "job", queued.Id, "specifier", specifier)
+	writeJSON(w, http.StatusAccepted, queued)
+}
+
+// List jobs, newest first. They can be filtered with the status, source, and trigger query parameters, and limit caps how many are listed.
+func (s *server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := 0
+	if query.Get("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 0 {
+			writeError(w, http.StatusBadRequest, "limit should be a positive number")
+			return
+		}
+	}
+	jobs := []job{}
+	for _, j := range s.jobs.list() {
+		if limit > 0 && len(jobs) == limit {
+			break
+		}
+		if (query.Get("status") != "" && j.Status != query.Get("status")) ||
+			(query.Get("source") != "" && j.Source != query.Get("source")) ||
+			(query.Get("trigger") != "" && j.Trigger != query.Get("trigger")) {
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"jobs": jobs})
+}
+
+func (s *server) handleJob(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.jobs.get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, j)
+}
+
+// Check a "sha256=<hex>" HMAC signature of the body, as sent by GitHub and the webhook destination
+func validSignature(header string, body []byte, secret string) bool {
+	signature, found := strings.CutPrefix(header, "sha256=")
+	if !found {
+		return false
+	}
+	return hmac.Equal([]byte(strings.ToLower(signature)), []byte(platforms.SignPayload(body, secret)))
+}
+
+// Check an "Authorization: Bearer <token>" header
+func validBearer(header string, secret string) bool {
+	token, found := strings.CutPrefix(header, "Bearer ")
+	if !found {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error("Failed to write response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/slashtechno/cross-blogger/internal/platforms"
+)
+
+func TestValidSignature(t *testing.T) {
+	body := []byte(`{"ref":"refs/heads/main"}`)
+	signature := platforms.SignPayload(body, "secret")
+	tests := []struct {
+		name   string
+		header string
+		body   []byte
+		secret string
+		want   bool
+	}{
+		{name: "valid", header: "sha256=" + signature, body: body, secret: "secret", want: true},
+		{name: "uppercase hex", header: "sha256=" + strings.ToUpper(signature), body: body, secret: "secret", want: true},
+		{name: "missing prefix", header: signature, body: body, secret: "secret", want: false},
+		{name: "sha1 prefix", header: "sha1=" + signature, body: body, secret: "secret", want: false},
+		{name: "wrong secret", header: "sha256=" + signature, body: body, secret: "other", want: false},
+		{name: "changed body", header: "sha256=" + signature, body: []byte(`{"ref":"refs/heads/other"}`), secret: "secret", want: false},
+		{name: "empty header", header: "", body: body, secret: "secret", want: false},
+		{name: "empty signature", header: "sha256=", body: body, secret: "secret", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validSignature(tt.header, tt.body, tt.secret); got != tt.want {
+				t.Errorf("validSignature(%q) = %t, want %t", tt.header, got, tt.want)
+			}
+		})
+	}
+}
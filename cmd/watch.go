@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"errors"
+	"net/http"
 	"sync"
 
 	"github.com/charmbracelet/log"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/slashtechno/cross-blogger/internal"
 	"github.com/slashtechno/cross-blogger/internal/platforms"
 	"github.com/spf13/cobra"
@@ -17,6 +20,7 @@ var watchCmd = &cobra.Command{
 	Specify the source with the first positional argument.
 	The second positional argument and on are treated as destination names.
 	Ensure that these are configured in the config file.
+	If routes are configured, each post is only pushed to the destinations its routes send it to.
 	`,
 	// Arg 1: Source
 	// Arg 2+: Destinations
@@ -41,6 +45,11 @@ var watchCmd = &cobra.Command{
 		if !found {
 			log.Fatal("Source not found", "source", args[0])
 		}
+		// Routes decide which of the destinations each new post is pushed to
+		routes, err := loadRoutes()
+		if err != nil {
+			log.Fatal(err)
+		}
 		// Assert that the source is a WatchableSource
 		watcher, ok := source.(platforms.WatchableSource)
 		if !ok {
@@ -66,26 +75,52 @@ var watchCmd = &cobra.Command{
 				LlmBaseUrl:  internal.CredentialViper.GetString("llm_base_url"),
 				LlmApiKey:   internal.CredentialViper.GetString("llm_api_key"),
 				LlmModel:    internal.CredentialViper.GetString("llm_model"),
+				// Used when cleaning up posts in a Markdown destination with git_dir set
+				ForgeToken:           internal.CredentialViper.GetString("forge_token"),
+				GitToken:             internal.CredentialViper.GetString("git_token"),
+				GitSshPassphrase:     internal.CredentialViper.GetString("git_ssh_passphrase"),
+				GitSigningPassphrase: internal.CredentialViper.GetString("git_signing_passphrase"),
 			}
 		default:
 			log.Fatal("Source type not implemented", "source", source.GetType())
 		}
 		// Channels are used to pass data between the goroutines
-		postChan := make(chan platforms.PostData)
+		postChan := make(chan []platforms.PostData)
 		errChan := make(chan error)
 		var wg sync.WaitGroup
+		// The goroutines stop on errors, so the health check reports whether they're all still running
+		health := newGoroutineHealth()
+		if address := internal.ConfigViper.GetString("metrics_address"); address != "" {
+			mux := http.NewServeMux()
+			mux.Handle("GET /metrics", promhttp.Handler())
+			mux.Handle("GET /healthz", health)
+			go func() {
+				log.Info("Serving metrics and health", "address", address)
+				if err := http.ListenAndServe(address, mux); err != nil {
+					log.Fatal("Failed to serve metrics", "error", err)
+				}
+			}()
+		}
 
 		// Start watching the source in a separate goroutine
 		// This will send new posts to postChan and errors to errChan
 		wg.Add(1)
-		go watcher.Watch(&wg, internal.ConfigViper.GetDuration("interval"), options, postChan, errChan)
+		health.start("watch:" + source.GetName())
+		go func() {
+			watcher.Watch(&wg, internal.ConfigViper.GetDuration("interval"), options, postChan, errChan)
+			health.stop("watch:" + source.GetName())
+		}()
 		if ok {
 			for _, dest := range destinationSlice {
 				if markdownDest, ok := dest.(*platforms.Markdown); ok {
 					// Check if overwriting is enabled
 					if markdownDest.Overwrite {
 						wg.Add(1)
-						go watcher.CleanMarkdownPosts(&wg, internal.ConfigViper.GetDuration("interval"), markdownDest, options, errChan)
+						health.start("clean:" + dest.GetName())
+						go func(markdownDest *platforms.Markdown) {
+							watcher.CleanMarkdownPosts(&wg, internal.ConfigViper.GetDuration("interval"), markdownDest, options, errChan)
+							health.stop("clean:" + markdownDest.GetName())
+						}(markdownDest)
 					} else {
 						log.Debug("Overwriting is disabled; not cleaning up posts", "destination", dest.GetName())
 					}
@@ -101,19 +136,35 @@ var watchCmd = &cobra.Command{
 			for {
 				// Wait for something to happen
 				select {
-				// If a new post arrives
-				case post := <-postChan:
-					// Log the new post
-					log.Info("Posting", "title", post.Title)
-					err := pushToDestinations(post, destinationSlice, false)
-
+				// If new posts arrive
+				case posts := <-postChan:
+					// Markdown destinations with batching enabled commit every post found in this tick at once
+					batches, err := beginGitBatches(destinationSlice, false)
 					if err != nil {
 						log.Fatal("Error", "error", err)
 					}
+					// Rebuild the index for each tick so it includes the posts that were just published
+					links := buildLinkIndex(source, options, destinationSlice)
+					for _, post := range posts {
+						post.Source = source.GetName()
+						// Log the new post
+						log.Info("Posting", append(platforms.LogFields(post), "title", post.Title)...)
+						err := pushToDestinations(post, routeDestinations(routes, post, source, destinationSlice), links, batches, false)
+						// A pre_push or pre_commit hook stopping a push is expected, such as when a post fails linting, so it isn't fatal
+						if errors.Is(err, platforms.ErrPrePushAborted) {
+							log.Error("A hook stopped the post from being pushed to some destinations", "title", post.Title, "error", err)
+						} else if err != nil {
+							log.Fatal("Error", "error", err)
+						}
+					}
+					if err := batches.commit(destinationSlice); err != nil {
+						log.Fatal("Error", "error", err)
+					}
 				// If an error occurs
 				case err := <-errChan:
 					// Log the error
 					log.Error("Error", "error", err)
+					health.recordError(err)
 				}
 			}
 		}()
@@ -126,4 +177,6 @@ func init() {
 	// The interval can be parsed with the time.ParseDu	ration function
 	watchCmd.Flags().StringP("interval", "i", "30s", "Interval to check for new content")
 	internal.ConfigViper.BindPFlag("interval", watchCmd.Flags().Lookup("interval"))
+	watchCmd.Flags().String("metrics-address", "", "Address to serve Prometheus metrics at /metrics and a health check at /healthz, such as :9090")
+	internal.ConfigViper.BindPFlag("metrics_address", watchCmd.Flags().Lookup("metrics-address"))
 }
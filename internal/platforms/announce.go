@@ -0,0 +1,105 @@
+package platforms
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The default template for destinations that announce new posts, such as Mastodon and Bluesky.
+// It's executed with an Announcement.
+const DefaultAnnouncementTemplate = `{{.Title}}
+{{if .Description}}
+{{.Description}}
+{{end}}{{if .CanonicalUrl}}
+{{.CanonicalUrl}}
+{{end}}{{if .Hashtags}}
+{{.Hashtags}}{{end}}`
+
+// Matches links in the text of an announcement
+var announcementLinkRegex = regexp.MustCompile(`https?://[^\s]*[^\s.,;:!?"')\]]`)
+
+// Announcement is the data passed to the templates of destinations that announce new posts
+type Announcement struct {
+	PostData
+	// Hashtags are the post's tags as hashtags, separated by spaces
+	Hashtags string
+}
+
+// Render an announcement, making it fit within the limit as measured by length.
+// Hashtags are dropped first, starting with the last one, and then the description is shortened.
+func renderAnnouncement(tmpl *template.Template, data PostData, limit int, length func(string) int) (string, error) {
+	hashtags := Hashtags(data.Tags)
+	announcement := Announcement{PostData: data, Hashtags: strings.Join(hashtags, " ")}
+	render := func() (string, error) {
+		text, err := executeTemplate(tmpl, announcement)
+		if err != nil {
+			return "", fmt.Errorf("failed to render announcement: %w", err)
+		}
+		return strings.TrimSpace(text), nil
+	}
+	text, err := render()
+	if err != nil {
+		return "", err
+	}
+	for length(text) > limit && len(hashtags) > 0 {
+		hashtags = hashtags[:len(hashtags)-1]
+		announcement.Hashtags = strings.Join(hashtags, " ")
+		if text, err = render(); err != nil {
+			return "", err
+		}
+	}
+	if over := length(text) - limit; over > 0 && announcement.Description != "" {
+		announcement.Description = truncateWithEllipsis(announcement.Description, utf8.RuneCountInString(announcement.Description)-over)
+		if text, err = render(); err != nil {
+			return "", err
+		}
+	}
+	if length(text) > limit {
+		return "", fmt.Errorf("announcement is %d characters, which is over the limit of %d", length(text), limit)
+	}
+	return text, nil
+}
+
+// Shorten text to at most n characters, ending with an ellipsis if anything was cut off.
+// Words aren't split unless there's only one.
+func truncateWithEllipsis(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	if n <= 1 {
+		return ""
+	}
+	cut := string(runes[:n-1])
+	if i := strings.LastIndexFunc(cut, unicode.IsSpace); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRightFunc(cut, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsPunct(r) }) + "…"
+}
+
+// Hashtags converts tags to hashtags, such as "#MachineLearning" for "machine learning".
+// Tags that can't be hashtags, such as those that are only numbers, are left out, as are duplicates.
+func Hashtags(tags []string) []string {
+	hashtags := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		words := strings.FieldsFunc(tag, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsNumber(r) })
+		if len(words) > 1 {
+			for i, word := range words {
+				r, size := utf8.DecodeRuneInString(word)
+				words[i] = string(unicode.ToUpper(r)) + word[size:]
+			}
+		}
+		hashtag := strings.Join(words, "")
+		if strings.IndexFunc(hashtag, unicode.IsLetter) == -1 || seen[strings.ToLower(hashtag)] {
+			continue
+		}
+		seen[strings.ToLower(hashtag)] = true
+		hashtags = append(hashtags, "#"+hashtag)
+	}
+	return hashtags
+}
@@ -0,0 +1,93 @@
+package platforms
+
+import (
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+)
+
+// Defaults used when an attribution table is present but doesn't set the templates
+const (
+	DefaultAttributionHtml     = `<p class="cross-blogger-attribution"><em>Originally published at <a href="{{.CanonicalUrl}}">{{.CanonicalUrl}}</a>.</em></p>`
+	DefaultAttributionMarkdown = `*Originally published at [{{.CanonicalUrl}}]({{.CanonicalUrl}}).*`
+)
+
+// Attribution adds an "Originally published at" block to posts pushed to platforms that can't set a canonical URL.
+// This way, readers (and search engines) can still find the original post.
+type Attribution struct {
+	Html     *htmltemplate.Template
+	Markdown *template.Template
+	// Prepend places the block at the start of the post instead of the end
+	Prepend bool
+	// CanonicalLink also adds a <link rel="canonical"> element to the HTML, for platforms that allow raw HTML in posts
+	CanonicalLink bool
+}
+
+// Convert the attribution table of a destination (interface{} due to how Viper works) to an Attribution struct.
+// If the table isn't set, nil is returned.
+func AttributionFromInterface(a interface{}) (*Attribution, error) {
+	if a == nil {
+		return nil, nil
+	}
+	attributionMap, ok := a.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("attribution is not a table")
+	}
+	htmlTemplate, ok := attributionMap["html"].(string)
+	if !ok || htmlTemplate == "" {
+		htmlTemplate = DefaultAttributionHtml
+	}
+	markdownTemplate, ok := attributionMap["markdown"].(string)
+	if !ok || markdownTemplate == "" {
+		markdownTemplate = DefaultAttributionMarkdown
+	}
+	attribution := &Attribution{}
+	var err error
+	// html/template is used for the HTML variant so the URL and title are escaped
+	attribution.Html, err = htmltemplate.New("attribution").Parse(htmlTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse attribution html: %w", err)
+	}
+	attribution.Markdown, err = template.New("attribution").Parse(markdownTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse attribution markdown: %w", err)
+	}
+	switch attributionMap["position"] {
+	case nil, "", "append":
+	case "prepend":
+		attribution.Prepend = true
+	default:
+		return nil, fmt.Errorf("unknown attribution position: %s", attributionMap["position"])
+	}
+	attribution.CanonicalLink, _ = attributionMap["canonical_link"].(bool)
+	return attribution, nil
+}
+
+// Add the attribution block to both the HTML and Markdown of the post.
+// Posts without a canonical URL are returned unchanged as there's nothing to attribute.
+func (a Attribution) Transform(data PostData) (PostData, error) {
+	if data.CanonicalUrl == "" {
+		return data, nil
+	}
+	var htmlBuf strings.Builder
+	if err := a.Html.Execute(&htmlBuf, data); err != nil {
+		return PostData{}, err
+	}
+	markdown, err := executeTemplate(a.Markdown, data)
+	if err != nil {
+		return PostData{}, err
+	}
+	if a.Prepend {
+		data.Html = htmlBuf.String() + "\n" + data.Html
+		data.Markdown = markdown + "\n\n" + data.Markdown
+	} else {
+		data.Html = data.Html + "\n" + htmlBuf.String()
+		data.Markdown = strings.TrimRight(data.Markdown, "\n") + "\n\n" + markdown
+	}
+	if a.CanonicalLink {
+		data.Html = fmt.Sprintf(`<link rel="canonical" href="%s" />`, htmltemplate.HTMLEscapeString(data.CanonicalUrl)) + "\n" + data.Html
+	}
+	return data, nil
+}
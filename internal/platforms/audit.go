@@ -0,0 +1,171 @@
+package platforms
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gosimple/slug"
+)
+
+// AuditEntry is a line of the audit log, recording a change to a post on a destination
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	// Action is "push" the first time a post is pushed to a destination, "update" after that, and "delete" when it's deleted
+	Action string `json:"action"`
+	// Result is "success" or "error"
+	Result          string `json:"result"`
+	Error           string `json:"error,omitempty"`
+	Source          string `json:"source,omitempty"`
+	Destination     string `json:"destination"`
+	DestinationType string `json:"destination_type"`
+	PostId          string `json:"post_id"`
+	Slug            string `json:"slug"`
+	Title           string `json:"title,omitempty"`
+	// BeforeHash is the hash of the post the last time it was pushed to the destination, and AfterHash is the hash of the post that was pushed.
+	// Hashes are of the post as JSON, after transforms, as "sha256:<hex>".
+	BeforeHash string `json:"before_hash,omitempty"`
+	AfterHash  string `json:"after_hash,omitempty"`
+}
+
+// AuditLog appends a JSON Lines record of every push, update, and delete to File.
+// If File is empty, nothing is recorded.
+type AuditLog struct {
+	File string
+	lock sync.Mutex
+	// hashes maps each destination and post key to the hash of the post the last time it was pushed there, read from the file when it's first needed
+	hashes map[string]string
+}
+
+// Audit is the audit log used by every command. Its file is set from the config.
+var Audit = &AuditLog{}
+
+// RecordPush records pushing the post to the destination, as an update if it was pushed there before.
+// err is the error the push returned, if any.
+func (a *AuditLog) RecordPush(destination Destination, data PostData, err error) error {
+	return a.record(destination, data, false, err)
+}
+
+// RecordDelete records deleting the post from the destination
+func (a *AuditLog) RecordDelete(destination Destination, data PostData, err error) error {
+	return a.record(destination, data, true, err)
+}
+
+func (a *AuditLog) record(destination Destination, data PostData, deleted bool, pushErr error) error {
+	if a.File == "" {
+		return nil
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if err := a.load(); err != nil {
+		return err
+	}
+	entry := AuditEntry{
+		Time:            time.Now().UTC(),
+		Action:          "push",
+		Result:          resultLabel(pushErr),
+		Source:          data.Source,
+		Destination:     destination.GetName(),
+		DestinationType: destination.GetType(),
+		PostId:          PostKey(data),
+		Slug:            slug.Make(data.Title),
+		Title:           data.Title,
+	}
+	key := entry.Destination + "\xff" + entry.PostId
+	entry.BeforeHash = a.hashes[key]
+	if deleted {
+		entry.Action = "delete"
+	} else {
+		if entry.BeforeHash != "" {
+			entry.Action = "update"
+		}
+		var err error
+		entry.AfterHash, err = hashPost(data)
+		if err != nil {
+			return err
+		}
+	}
+	if pushErr != nil {
+		entry.Error = pushErr.Error()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.File), 0755); err != nil {
+		return err
+	}
+	// The file is only ever appended to
+	file, err := os.OpenFile(a.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	// Only changes that happened are remembered as the destination's current version
+	if pushErr == nil {
+		a.hashes[key] = entry.AfterHash
+	}
+	return nil
+}
+
+// Read the hash of the current version of each post from the file, if it hasn't been read yet. The caller must hold the lock.
+func (a *AuditLog) load() error {
+	if a.hashes != nil {
+		return nil
+	}
+	hashes := map[string]string{}
+	file, err := os.Open(a.File)
+	if errors.Is(err, os.ErrNotExist) {
+		a.hashes = hashes
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	// Lines are short, but allow for long titles and errors
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("failed to read line %d of %s: %w", line, a.File, err)
+		}
+		if entry.Result == "success" {
+			hashes[entry.Destination+"\xff"+entry.PostId] = entry.AfterHash
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	a.hashes = hashes
+	return nil
+}
+
+// Return the SHA-256 hash of the post as JSON
+func hashPost(data PostData) (string, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// LogFields returns the fields that identify a post in logs: its source, its ID (the key it's remembered by), and its slug
+func LogFields(data PostData) []interface{} {
+	return []interface{}{"source", data.Source, "post_id", PostKey(data), "slug", slug.Make(data.Title)}
+}
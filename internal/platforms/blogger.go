@@ -2,14 +2,16 @@ package platforms
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/url"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
-	md "github.com/JohannesKaufmann/html-to-markdown"
 	"github.com/charmbracelet/log"
 	"github.com/go-resty/resty/v2"
 	"github.com/gosimple/slug"
@@ -93,8 +95,15 @@ func (b Blogger) Pull(options PushPullOptions) (PostData, error) {
 		return PostData{}, fmt.Errorf("failed to get post: %s", resp.String())
 	}
 	log.Debug("Got response", "response", resp.String())
+	return b.postFromResult(*resp.Result().(*map[string]interface{}), options)
+}
+
+// Convert a post returned by the Blogger API into PostData
+func (b Blogger) postFromResult(result map[string]interface{}, options PushPullOptions) (PostData, error) {
+	// Drafts don't have a URL or (if they were never published) a publish date
+	status, _ := result["status"].(string)
+	draft := status == "DRAFT"
 	// Get the keys "title" and "content" from the response
-	result := (*resp.Result().(*map[string]interface{}))
 	title, ok := result["title"].(string)
 	if !ok {
 		return PostData{}, fmt.Errorf("title not found in response or is not a string")
@@ -104,19 +113,22 @@ func (b Blogger) Pull(options PushPullOptions) (PostData, error) {
 		return PostData{}, fmt.Errorf("content not found in response or is not a string")
 	}
 	canonicalUrl, ok := result["url"].(string)
-	if !ok {
+	if !ok && !draft {
 		return PostData{}, fmt.Errorf("url not found in response or is not a string")
 	}
 
 	// Date published is returned like `"published": "2024-06-19T09:37:00-07:00,`
+	var date time.Time
 	rfcDate, ok := result["published"].(string)
-	if !ok {
+	if !ok && !draft {
 		return PostData{}, fmt.Errorf("published date not found in response or is not a string")
-	}
-	// The date is in RFC3339 format and needs to be converted to a time.Time object
-	date, err := time.Parse(time.RFC3339, rfcDate)
-	if err != nil {
-		return PostData{}, err
+	} else if ok {
+		// The date is in RFC3339 format and needs to be converted to a time.Time object
+		var err error
+		date, err = time.Parse(time.RFC3339, rfcDate)
+		if err != nil {
+			return PostData{}, err
+		}
 	}
 	rfcDateUpdated, ok := result["updated"].(string)
 	if !ok {
@@ -129,8 +141,6 @@ func (b Blogger) Pull(options PushPullOptions) (PostData, error) {
 
 	// Declare the slices here so they can be used outside the if statement
 	labels := []string{}
-	categories := []string{}
-	tags := []string{}
 	// Assert that the labels key is a slice of interfaces
 	// Labels itself is of an unknown type, so its elements need to be asserted to be strings
 	labelsAsserted, ok := result["labels"].([]interface{})
@@ -144,63 +154,22 @@ func (b Blogger) Pull(options PushPullOptions) (PostData, error) {
 				log.Warn("Blogger label is not a string", "tag", tag)
 			}
 		}
-		// For each label, check if it has the category prefix
-		// If it does, add it to the categories slice
-		// Otherwise, add it to the tags slice
-		for _, label := range labels {
-			if strings.HasPrefix(label, b.CategoryPrefix) {
-				categories = append(categories, strings.TrimPrefix(label, b.CategoryPrefix))
-			} else {
-				tags = append(tags, label)
-			}
-		}
 	}
+	categories, tags := splitLabels(labels, b.CategoryPrefix)
 
 	// Convert the HTML to markdown
-	markdown, err := md.NewConverter("", true, nil).ConvertString(html)
+	markdown, err := HtmlToMarkdown(html, b.Embeds)
 	if err != nil {
 		return PostData{}, err
 	}
 
 	// If GenerateLlmDescriptions is true, generate descriptions for the post
 	var postDescription string
-	var llmImplementation llms.Model
-	prompt := fmt.Sprintf("The following is a blog post titled \"%s\" with the content:\n\n%s\n\nThe description of the post is:", title, markdown)
 	if b.GenerateLlmDescriptions {
-		switch strings.ToLower(options.LlmProvider) {
-		case "openai":
-			// If the API key is not set warn
-			// If the base URL or model is not set, return an error
-			if options.LlmApiKey == "" {
-				log.Warn("No key for an OpenAI-compatible API was provided")
-			}
-			if options.LlmBaseUrl == "" || options.LlmModel == "" {
-				return PostData{}, fmt.Errorf("OpenAI base URL and model are required")
-			}
-			llmImplementation, err = openai.New(openai.WithBaseURL(options.LlmBaseUrl), openai.WithModel(options.LlmModel), openai.WithToken(options.LlmApiKey))
-			if err != nil {
-				return PostData{}, err
-			}
-		case "ollama":
-			// If base URL is set, use that. Otherwise, use http://localhost:11434
-			baseUrl := options.LlmBaseUrl
-			if baseUrl == "" {
-				baseUrl = "http://localhost:11434"
-			}
-			llmImplementation, err = ollama.New(ollama.WithServerURL(baseUrl), ollama.WithModel(options.LlmModel))
-			if err != nil {
-				return PostData{}, err
-			}
-		default:
-			return PostData{}, fmt.Errorf("invalid LLM platform")
-		}
-		ctx := context.Background()
-		postDescription, err = llms.GenerateFromSinglePrompt(ctx, llmImplementation, prompt)
+		postDescription, err = generateLlmDescription(title, markdown, options)
 		if err != nil {
 			return PostData{}, err
 		}
-		postDescription = strings.TrimSpace(postDescription)
-		log.Debug("Generated description", "description", postDescription)
 	}
 
 	// Once all the data is retrieved, return it
@@ -214,10 +183,68 @@ func (b Blogger) Pull(options PushPullOptions) (PostData, error) {
 		Categories:   categories,
 		Tags:         tags,
 		CanonicalUrl: canonicalUrl,
+		Draft:        draft,
 	}, nil
 
 }
 
+// Split Blogger labels into categories and tags.
+// Labels with the category prefix become categories (without the prefix) and the rest become tags.
+func splitLabels(labels []string, categoryPrefix string) (categories []string, tags []string) {
+	categories = []string{}
+	tags = []string{}
+	for _, label := range labels {
+		if strings.HasPrefix(label, categoryPrefix) {
+			categories = append(categories, strings.TrimPrefix(label, categoryPrefix))
+		} else {
+			tags = append(tags, label)
+		}
+	}
+	return categories, tags
+}
+
+// Generate a description for a post with the LLM configured in the options
+func generateLlmDescription(title string, markdown string, options PushPullOptions) (string, error) {
+	var err error
+	var llmImplementation llms.Model
+	prompt := fmt.Sprintf("The following is a blog post titled \"%s\" with the content:\n\n%s\n\nThe description of the post is:", title, markdown)
+	switch strings.ToLower(options.LlmProvider) {
+	case "openai":
+		// If the API key is not set warn
+		// If the base URL or model is not set, return an error
+		if options.LlmApiKey == "" {
+			log.Warn("No key for an OpenAI-compatible API was provided")
+		}
+		if options.LlmBaseUrl == "" || options.LlmModel == "" {
+			return "", fmt.Errorf("OpenAI base URL and model are required")
+		}
+		llmImplementation, err = openai.New(openai.WithBaseURL(options.LlmBaseUrl), openai.WithModel(options.LlmModel), openai.WithToken(options.LlmApiKey))
+		if err != nil {
+			return "", err
+		}
+	case "ollama":
+		// If base URL is set, use that. Otherwise, use http://localhost:11434
+		baseUrl := options.LlmBaseUrl
+		if baseUrl == "" {
+			baseUrl = "http://localhost:11434"
+		}
+		llmImplementation, err = ollama.New(ollama.WithServerURL(baseUrl), ollama.WithModel(options.LlmModel))
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("invalid LLM platform")
+	}
+	ctx := context.Background()
+	postDescription, err := llms.GenerateFromSinglePrompt(ctx, llmImplementation, prompt)
+	if err != nil {
+		return "", err
+	}
+	postDescription = strings.TrimSpace(postDescription)
+	log.Debug("Generated description", "description", postDescription)
+	return postDescription, nil
+}
+
 // Push PostData to the blog.
 func (b Blogger) Push(data PostData, options PushPullOptions) error {
 	// Set the client
@@ -255,13 +282,29 @@ func (b Blogger) Push(data PostData, options PushPullOptions) error {
 			}
 		}
 	}
-	log.Warn("Blogger does not support setting the canonical URL")
+	// Turn shortcodes back into embeds
+	if len(b.Embeds) > 0 {
+		data.Html = ExpandShortcodes(data.Html, b.Embeds)
+	}
+	if b.Attribution != nil {
+		var err error
+		data, err = b.Attribution.Transform(data)
+		if err != nil {
+			return err
+		}
+	} else if data.CanonicalUrl != "" {
+		log.Warn("Blogger does not support setting the canonical URL. Set attribution to credit the original post")
+	}
 	// Prepare the request
 	req := client.R().SetHeader("Authorization", fmt.Sprintf("Bearer %s", options.AccessToken)).SetBody(map[string]interface{}{
 		"title":   data.Title,
 		"content": data.Html,
 		// "url":     data.CanonicalUrl,
 	}).SetResult(&map[string]interface{}{})
+	// Drafts stay drafts on Blogger
+	if data.Draft {
+		req.SetQueryParam("isDraft", "true")
+	}
 	// Make the request
 	resp, err := req.Post("https://www.googleapis.com/blogger/v3/blogs/" + blogId + "/posts")
 	if err != nil {
@@ -276,7 +319,8 @@ func (b Blogger) Push(data PostData, options PushPullOptions) error {
 }
 
 // Every interval, check for new posts (posts that haven't been seen before) and send them to the postChan channel.
-func (b *Blogger) Watch(wg *sync.WaitGroup, interval time.Duration, options PushPullOptions, postChan chan<- PostData, errChan chan<- error) {
+// The posts found in each interval are sent together.
+func (b *Blogger) Watch(wg *sync.WaitGroup, interval time.Duration, options PushPullOptions, postChan chan<- []PostData, errChan chan<- error) {
 	// A ticker works by sending a message to the channel every interval
 	ticker := time.NewTicker(interval)
 	// Defer the stopping of the ticker and the decrementing of the wait group
@@ -289,13 +333,19 @@ func (b *Blogger) Watch(wg *sync.WaitGroup, interval time.Duration, options Push
 		// Refresh the access token
 		var err error
 		// Get the access token
+		start := time.Now()
 		options.AccessToken, _, err = b.Authorize(options.ClientId, options.ClientSecret, options.RefreshToken)
+		observeRequest(b.Name, "authorize", start)
 		if err != nil {
+			PollsTotal.WithLabelValues(b.Name, resultLabel(err)).Inc()
 			errChan <- err
 			return
 		}
 		// Fetch new posts using the fresh access token
+		start = time.Now()
 		posts, err := b.fetchNewPosts(options)
+		observeRequest(b.Name, "fetch_posts", start)
+		PollsTotal.WithLabelValues(b.Name, resultLabel(err)).Inc()
 		if err != nil {
 			errChan <- err
 			// Continue will skip the rest of the loop and go to the next iteration
@@ -303,37 +353,57 @@ func (b *Blogger) Watch(wg *sync.WaitGroup, interval time.Duration, options Push
 			// continue
 			return
 		}
+		LastSuccessfulPoll.WithLabelValues(b.Name).SetToCurrentTime()
+		NewPostsTotal.WithLabelValues(b.Name).Add(float64(len(posts)))
 
 		// Send new posts to the channel to be pushed
-		for _, post := range posts {
-			postChan <- post
+		if len(posts) > 0 {
+			postChan <- posts
 		}
 	}
 }
 
+// Fetch every live post on the blog
 func (b *Blogger) fetchPosts(blogId, accessToken string) ([]map[string]interface{}, error) {
-	// Get the list of posts
+	return b.fetchAllPosts(blogId, accessToken, url.Values{"status": {"LIVE"}})
+}
+
+// Fetch every post matching the query, following nextPageToken until the last page
+func (b *Blogger) fetchAllPosts(blogId, accessToken string, query url.Values) ([]map[string]interface{}, error) {
 	client := resty.New()
-	resp, err := client.R().
-		SetHeader("Authorization", fmt.Sprintf("Bearer %s", accessToken)).
-		SetResult(&map[string]interface{}{}).
-		SetQueryParam("fetchBodies", "true").
-		SetQueryParam("status", "LIVE").
-		Get("https://www.googleapis.com/blogger/v3/blogs/" + blogId + "/posts")
-	if err != nil {
-		return nil, err
-	}
-	// Assert that posts is a pointer to a map. However, dereference it to get the map
-	posts := (*resp.Result().(*map[string]interface{}))["items"].([]interface{})
-	// Make another slice but assert each element to be a map of string to interface
 	postsValidated := []map[string]interface{}{}
-	for _, p := range posts {
-		if post, ok := p.(map[string]interface{}); ok {
-			postsValidated = append(postsValidated, post)
+	pageToken := ""
+	for {
+		req := client.R().
+			SetHeader("Authorization", fmt.Sprintf("Bearer %s", accessToken)).
+			SetResult(&map[string]interface{}{}).
+			SetQueryParamsFromValues(query).
+			SetQueryParam("fetchBodies", "true").
+			SetQueryParam("maxResults", "500")
+		if pageToken != "" {
+			req.SetQueryParam("pageToken", pageToken)
+		}
+		resp, err := req.Get("https://www.googleapis.com/blogger/v3/blogs/" + blogId + "/posts")
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode() != 200 {
+			return nil, fmt.Errorf("failed to get posts: %s", resp.String())
+		}
+		result := (*resp.Result().(*map[string]interface{}))
+		// items is left out when there are no posts
+		posts, _ := result["items"].([]interface{})
+		// Make another slice but assert each element to be a map of string to interface
+		for _, p := range posts {
+			if post, ok := p.(map[string]interface{}); ok {
+				postsValidated = append(postsValidated, post)
+			}
+		}
+		pageToken, _ = result["nextPageToken"].(string)
+		if pageToken == "" {
+			return postsValidated, nil
 		}
-		postsValidated = append(postsValidated, p.(map[string]interface{}))
 	}
-	return postsValidated, nil
 }
 
 // Get posts that haven't been seen before and return them
@@ -456,6 +526,8 @@ func (b Blogger) CleanMarkdownPosts(wg *sync.WaitGroup, interval time.Duration,
 				unkownFiles = append(unkownFiles, file.Name())
 			}
 		}
+		// If batching is enabled, every deletion in this tick is committed at once
+		var batch *GitBatch
 		// Pull the frontmatter for each file
 		for _, file := range unkownFiles {
 			// Get absolute path
@@ -469,22 +541,57 @@ func (b Blogger) CleanMarkdownPosts(wg *sync.WaitGroup, interval time.Duration,
 			markdownString := string(fileBytes)
 			// Get the frontmatter for the file
 			_, _, postFrontmatter, err := markdownDest.ParseMarkdown(markdownString)
+			// Files without a title, such as _index.md, weren't written by cross-blogger
+			if errors.Is(err, ErrNoTitle) {
+				continue
+			}
 			if err != nil {
 				errChan <- err
 				return
 			}
 			log.Debug("Got frontmatter", "frontmatter", postFrontmatter)
 			if postFrontmatter.Managed {
+				slug := strings.TrimSuffix(file, filepath.Ext(file))
+				// Get the repository ready before deleting the file
+				var baseBranch string
+				if markdownDest.GitDir != "" && markdownDest.Git.Batch {
+					// Only start a batch once there's something to delete
+					if batch == nil {
+						batch, err = markdownDest.BeginBatch(options)
+						if err != nil {
+							errChan <- err
+							return
+						}
+					}
+				} else if markdownDest.GitDir != "" {
+					baseBranch, err = markdownDest.PrepareGit(slug, options)
+					if err != nil {
+						errChan <- err
+						return
+					}
+				}
 				// Delete the file
+				deletedPost := PostData{Title: postFrontmatter.Title, Description: postFrontmatter.Description, CanonicalUrl: postFrontmatter.CanonicalUrl, Source: b.Name}
 				err := fs.Remove(absPath)
+				if auditErr := Audit.RecordDelete(markdownDest, deletedPost, err); auditErr != nil {
+					log.Error("Failed to write to the audit log", "error", auditErr)
+				}
 				if err != nil {
 					errChan <- err
 					return
 				}
+				log.Info("Deleted post that is no longer on the source", append(LogFields(deletedPost), "destination", markdownDest.Name)...)
+				change := GitChange{
+					Slug:    slug,
+					Post:    deletedPost,
+					Deleted: true,
+					Base:    baseBranch,
+				}
 				// Comit and push the changes
-				if markdownDest.GitDir != "" {
-					slug := strings.TrimSuffix(file, filepath.Ext(file))
-					commitHash, err := markdownDest.Commit(slug, true)
+				if batch != nil {
+					batch.Add(change)
+				} else if markdownDest.GitDir != "" {
+					commitHash, err := markdownDest.Commit(change, true, options)
 					log.Info("Committed and pushed changes", "hash", commitHash)
 					if err != nil {
 						errChan <- err
@@ -493,8 +600,125 @@ func (b Blogger) CleanMarkdownPosts(wg *sync.WaitGroup, interval time.Duration,
 				}
 			}
 		}
+		if batch != nil {
+			deleted := batch.Len()
+			commitHash, err := markdownDest.CommitBatch(batch, true, options)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			log.Info("Committed and pushed deletions", "hash", commitHash, "posts", deleted)
+		}
+	}
+
+}
+
+// Build an index of every live post on the blog so links between posts can be rewritten.
+// If an access token isn't passed, one is obtained with the refresh token.
+func (b Blogger) BuildLinkIndex(options PushPullOptions) (LinkIndex, error) {
+	if options.AccessToken == "" {
+		var err error
+		options.AccessToken, _, err = b.Authorize(options.ClientId, options.ClientSecret, options.RefreshToken)
+		if err != nil {
+			return LinkIndex{}, err
+		}
+	}
+	posts, err := b.fetchPosts(options.BlogId, options.AccessToken)
+	if err != nil {
+		return LinkIndex{}, err
+	}
+	index := LinkIndex{
+		BlogUrl: b.BlogUrl,
+		Slugs:   map[string]string{},
+	}
+	for _, post := range posts {
+		postUrl, ok := post["url"].(string)
+		if !ok {
+			continue
+		}
+		title, ok := post["title"].(string)
+		if !ok {
+			continue
+		}
+		key, err := linkIndexKey(postUrl)
+		if err != nil {
+			return LinkIndex{}, err
+		}
+		// Use the same slug as Markdown.Push so the link points at the right file
+		index.Slugs[key] = slug.Make(title)
+	}
+	return index, nil
+}
+
+// List every post on the blog that matches the filter, oldest first.
+// If an access token isn't passed, one is obtained with the refresh token.
+func (b Blogger) ListPosts(options PushPullOptions, filter PostFilter) ([]ListedPost, error) {
+	if options.AccessToken == "" {
+		var err error
+		options.AccessToken, _, err = b.Authorize(options.ClientId, options.ClientSecret, options.RefreshToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+	query := url.Values{"orderBy": {"published"}}
+	for _, status := range filter.statuses() {
+		query.Add("status", strings.ToUpper(status))
+	}
+	// Drafts and scheduled posts are only returned to the blog's authors
+	if !(len(query["status"]) == 1 && query.Get("status") == "LIVE") {
+		query.Set("view", "ADMIN")
+	}
+	if !filter.Since.IsZero() {
+		query.Set("startDate", filter.Since.Format(time.RFC3339))
+	}
+	if !filter.Until.IsZero() {
+		query.Set("endDate", filter.Until.Format(time.RFC3339))
+	}
+	posts, err := b.fetchAllPosts(options.BlogId, options.AccessToken, query)
+	if err != nil {
+		return nil, err
+	}
+	listed := []ListedPost{}
+	for _, post := range posts {
+		id, _ := post["id"].(string)
+		title, _ := post["title"].(string)
+		status, _ := post["status"].(string)
+		listedPost := ListedPost{
+			Id:     id,
+			Title:  title,
+			Status: strings.ToLower(status),
+			raw:    post,
+		}
+		// Drafts that were never published fall back to when they were last updated
+		date, ok := post["published"].(string)
+		if !ok {
+			date, _ = post["updated"].(string)
+		}
+		listedPost.Date, _ = time.Parse(time.RFC3339, date)
+		if labels, ok := post["labels"].([]interface{}); ok {
+			for _, label := range labels {
+				if label, ok := label.(string); ok {
+					listedPost.Labels = append(listedPost.Labels, label)
+				}
+			}
+		}
+		if filter.Matches(listedPost) {
+			listed = append(listed, listedPost)
+		}
 	}
+	// The API returns the newest posts first
+	slices.Reverse(listed)
+	return listed, nil
+}
 
+// Pull a post returned by ListPosts without fetching it again
+func (b Blogger) PullListed(post ListedPost, options PushPullOptions) (PostData, error) {
+	raw, ok := post.raw.(map[string]interface{})
+	if !ok {
+		return PostData{}, fmt.Errorf("post %s was not listed from Blogger", post.Id)
+	}
+	return b.postFromResult(raw, options)
 }
+
 func (b Blogger) GetName() string { return b.Name }
 func (b Blogger) GetType() string { return "blogger" }
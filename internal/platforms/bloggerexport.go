@@ -0,0 +1,327 @@
+package platforms
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gosimple/slug"
+)
+
+// BloggerExport reads posts from the Atom file made by Blogger's "Back up content" option or by Google Takeout.
+// Since it works on a file, no OAuth is needed.
+type BloggerExport struct {
+	Name string
+	// File is the path to the export, such as blog-01-31-2024.xml or Takeout's feed.atom
+	File string
+	// BlogUrl is used for post URLs when the export only has the path of each post.
+	// If empty, the blog URL in the export is used.
+	BlogUrl                 string
+	CategoryPrefix          string
+	GenerateLlmDescriptions bool
+	// Embeds are converted to shortcodes, the same way as with Blogger
+	Embeds []EmbedRule
+}
+
+func (b BloggerExport) GetName() string { return b.Name }
+func (b BloggerExport) GetType() string { return "blogger-export" }
+
+// Entries are either marked with a category in this scheme (older backups) or with blogger:type (Takeout)
+const (
+	atomKindScheme    = "http://schemas.google.com/g/2005#kind"
+	bloggerKindPrefix = "http://schemas.google.com/blogger/2008/kind#"
+)
+
+type bloggerExportFeed struct {
+	XMLName xml.Name             `xml:"http://www.w3.org/2005/Atom feed"`
+	Links   []atomLink           `xml:"http://www.w3.org/2005/Atom link"`
+	Entries []bloggerExportEntry `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+type bloggerExportEntry struct {
+	Id         string         `xml:"http://www.w3.org/2005/Atom id"`
+	Title      string         `xml:"http://www.w3.org/2005/Atom title"`
+	Content    string         `xml:"http://www.w3.org/2005/Atom content"`
+	Published  string         `xml:"http://www.w3.org/2005/Atom published"`
+	Updated    string         `xml:"http://www.w3.org/2005/Atom updated"`
+	Categories []atomCategory `xml:"http://www.w3.org/2005/Atom category"`
+	Links      []atomLink     `xml:"http://www.w3.org/2005/Atom link"`
+	Author     atomAuthor     `xml:"http://www.w3.org/2005/Atom author"`
+	// Older backups mark drafts with app:control and comments with thr:in-reply-to
+	Control struct {
+		Draft string `xml:"http://purl.org/atom/app# draft"`
+	} `xml:"http://purl.org/atom/app# control"`
+	InReplyTo struct {
+		Ref string `xml:"ref,attr"`
+	} `xml:"http://purl.org/syndication/thread/1.0 in-reply-to"`
+	// Takeout exports use the blogger namespace instead
+	Type            string `xml:"http://schemas.google.com/blogger/2018 type"`
+	Status          string `xml:"http://schemas.google.com/blogger/2018 status"`
+	Filename        string `xml:"http://schemas.google.com/blogger/2018 filename"`
+	Parent          string `xml:"http://schemas.google.com/blogger/2018 parent"`
+	MetaDescription string `xml:"http://schemas.google.com/blogger/2018 metaDescription"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type atomCategory struct {
+	Scheme string `xml:"scheme,attr"`
+	Term   string `xml:"term,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"http://www.w3.org/2005/Atom name"`
+	Uri  string `xml:"http://www.w3.org/2005/Atom uri"`
+}
+
+// A post read from the export, along with its comments
+type bloggerExportPost struct {
+	entry    bloggerExportEntry
+	id       string
+	url      string
+	status   string
+	date     time.Time
+	labels   []string
+	comments []Comment
+}
+
+// Return what the entry is, such as "post", "page", or "comment"
+func (e bloggerExportEntry) kind() string {
+	if e.Type != "" {
+		return strings.ToLower(e.Type)
+	}
+	for _, category := range e.Categories {
+		if category.Scheme == atomKindScheme {
+			return strings.TrimPrefix(category.Term, bloggerKindPrefix)
+		}
+	}
+	return ""
+}
+
+// Return the labels of the entry. Every category that isn't the kind of entry is a label.
+func (e bloggerExportEntry) labels() []string {
+	labels := []string{}
+	for _, category := range e.Categories {
+		if category.Scheme != atomKindScheme {
+			labels = append(labels, category.Term)
+		}
+	}
+	return labels
+}
+
+// Return the href of the HTML alternate link, or an empty string
+func alternateLink(links []atomLink) string {
+	for _, link := range links {
+		if link.Rel == "alternate" && (link.Type == "" || link.Type == "text/html") {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+// Return the ID of a post from the Atom ID, such as 123 from tag:blogger.com,1999:blog-456.post-123
+func bloggerExportId(atomId string) string {
+	if i := strings.LastIndex(atomId, ".post-"); i != -1 {
+		return atomId[i+len(".post-"):]
+	}
+	return atomId
+}
+
+// Read the export and return its posts (oldest first) and the URL of the blog
+func (b BloggerExport) readExport() ([]bloggerExportPost, string, error) {
+	file, err := os.Open(b.File)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+	var feed bloggerExportFeed
+	if err := xml.NewDecoder(file).Decode(&feed); err != nil {
+		return nil, "", fmt.Errorf("failed to parse %s: %w", b.File, err)
+	}
+	blogUrl := b.BlogUrl
+	if blogUrl == "" {
+		blogUrl = alternateLink(feed.Links)
+	}
+
+	posts := []bloggerExportPost{}
+	comments := map[string][]Comment{}
+	for _, entry := range feed.Entries {
+		switch entry.kind() {
+		case "post":
+			post := bloggerExportPost{
+				entry:  entry,
+				id:     bloggerExportId(entry.Id),
+				url:    alternateLink(entry.Links),
+				labels: entry.labels(),
+			}
+			// Takeout only has the path of the post
+			if post.url == "" && entry.Filename != "" && blogUrl != "" {
+				post.url = strings.TrimSuffix(blogUrl, "/") + entry.Filename
+			}
+			// Drafts that were never published fall back to when they were last updated
+			date := entry.Published
+			if date == "" {
+				date = entry.Updated
+			}
+			post.date, _ = time.Parse(time.RFC3339, date)
+			switch {
+			case strings.EqualFold(entry.Status, "SOFT_TRASHED"), strings.EqualFold(entry.Status, "PURGED"):
+				log.Debug("Skipping deleted post", "title", entry.Title)
+				continue
+			case strings.EqualFold(entry.Control.Draft, "yes"), strings.EqualFold(entry.Status, "DRAFT"):
+				post.status = "draft"
+			case strings.EqualFold(entry.Status, "SCHEDULED"), post.date.After(time.Now()):
+				post.status = "scheduled"
+			default:
+				post.status = "live"
+			}
+			posts = append(posts, post)
+		case "comment":
+			parent := entry.Parent
+			if parent == "" {
+				parent = entry.InReplyTo.Ref
+			}
+			date, _ := time.Parse(time.RFC3339, entry.Published)
+			comments[bloggerExportId(parent)] = append(comments[bloggerExportId(parent)], Comment{
+				Author:    entry.Author.Name,
+				AuthorUrl: entry.Author.Uri,
+				Date:      date,
+				Html:      entry.Content,
+			})
+		default:
+			// Pages, settings, and the template aren't posts
+			continue
+		}
+	}
+	for i := range posts {
+		posts[i].comments = comments[posts[i].id]
+		sort.SliceStable(posts[i].comments, func(a, c int) bool { return posts[i].comments[a].Date.Before(posts[i].comments[c].Date) })
+	}
+	sort.SliceStable(posts, func(i, j int) bool { return posts[i].date.Before(posts[j].date) })
+	return posts, blogUrl, nil
+}
+
+// Convert a post from the export into PostData
+func (b BloggerExport) postData(post bloggerExportPost, options PushPullOptions) (PostData, error) {
+	categories, tags := splitLabels(post.labels, b.CategoryPrefix)
+	markdown, err := HtmlToMarkdown(post.entry.Content, b.Embeds)
+	if err != nil {
+		return PostData{}, err
+	}
+	description := post.entry.MetaDescription
+	if description == "" && b.GenerateLlmDescriptions {
+		description, err = generateLlmDescription(post.entry.Title, markdown, options)
+		if err != nil {
+			return PostData{}, err
+		}
+	}
+	var date time.Time
+	if post.status != "draft" {
+		date = post.date
+	}
+	dateUpdated, _ := time.Parse(time.RFC3339, post.entry.Updated)
+	return PostData{
+		Title:        post.entry.Title,
+		Html:         post.entry.Content,
+		Markdown:     markdown,
+		Date:         date,
+		DateUpdated:  dateUpdated,
+		Description:  description,
+		Categories:   categories,
+		Tags:         tags,
+		CanonicalUrl: post.url,
+		Draft:        post.status == "draft",
+		Comments:     post.comments,
+		Author:       post.entry.Author.Name,
+	}, nil
+}
+
+// Pull a post from the export. options.PostUrl can be the post's URL (or just its path) or its ID.
+func (b BloggerExport) Pull(options PushPullOptions) (PostData, error) {
+	posts, _, err := b.readExport()
+	if err != nil {
+		return PostData{}, err
+	}
+	specifier := options.PostUrl
+	specifierPath := specifier
+	if parsed, err := url.Parse(specifier); err == nil && parsed.Path != "" {
+		specifierPath = parsed.Path
+	}
+	for _, post := range posts {
+		if post.id == specifier || post.entry.Id == specifier {
+			return b.postData(post, options)
+		}
+		if post.url == "" {
+			continue
+		}
+		if parsed, err := url.Parse(post.url); err == nil && parsed.Path == specifierPath {
+			return b.postData(post, options)
+		}
+	}
+	return PostData{}, fmt.Errorf("post not found in %s: %s", b.File, specifier)
+}
+
+// List every post in the export that matches the filter, oldest first
+func (b BloggerExport) ListPosts(options PushPullOptions, filter PostFilter) ([]ListedPost, error) {
+	posts, _, err := b.readExport()
+	if err != nil {
+		return nil, err
+	}
+	listed := []ListedPost{}
+	for _, post := range posts {
+		listedPost := ListedPost{
+			Id:     post.id,
+			Title:  post.entry.Title,
+			Date:   post.date,
+			Labels: post.labels,
+			Status: post.status,
+			raw:    post,
+		}
+		if filter.Matches(listedPost) {
+			listed = append(listed, listedPost)
+		}
+	}
+	return listed, nil
+}
+
+// Pull a post returned by ListPosts
+func (b BloggerExport) PullListed(post ListedPost, options PushPullOptions) (PostData, error) {
+	exportPost, ok := post.raw.(bloggerExportPost)
+	if !ok {
+		return PostData{}, fmt.Errorf("post %s was not listed from a Blogger export", post.Id)
+	}
+	return b.postData(exportPost, options)
+}
+
+// Build an index of every live post in the export so links between posts can be rewritten
+func (b BloggerExport) BuildLinkIndex(options PushPullOptions) (LinkIndex, error) {
+	posts, blogUrl, err := b.readExport()
+	if err != nil {
+		return LinkIndex{}, err
+	}
+	index := LinkIndex{
+		BlogUrl: blogUrl,
+		Slugs:   map[string]string{},
+	}
+	for _, post := range posts {
+		if post.status != "live" || post.url == "" {
+			continue
+		}
+		key, err := linkIndexKey(post.url)
+		if err != nil {
+			return LinkIndex{}, err
+		}
+		// Use the same slug as Markdown.Push so the link points at the right file
+		index.Slugs[key] = slug.Make(post.entry.Title)
+	}
+	return index, nil
+}
@@ -0,0 +1,249 @@
+package platforms
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/charmbracelet/log"
+	"github.com/go-resty/resty/v2"
+)
+
+// Bluesky limits posts to 300 graphemes. Runes are counted instead, which is the same for most text.
+const blueskyCharacterLimit = 300
+
+// Bluesky rejects thumbnails larger than this
+const blueskyMaxThumbnailSize = 1000000
+
+// Bluesky announces new posts on Bluesky (or another AT Protocol service) with a link card for the post
+type Bluesky struct {
+	Name string
+	// Service is the URL of the account's PDS, such as https://bsky.social
+	Service string
+	// Handle is the handle (or DID) of the account to post as
+	Handle string
+	// Template is executed with an Announcement to make the text of the post
+	Template *template.Template
+	// Languages are the BCP 47 codes of the languages posts are written in, such as "en"
+	Languages []string
+	// PostIds remembers the record each post was announced in, so a post is only announced once
+	PostIds PostIds
+	Transforms
+	PushHooks
+}
+
+func (b Bluesky) GetName() string { return b.Name }
+func (b Bluesky) GetType() string { return "bluesky" }
+
+// BlueskySession is what's returned when logging in or refreshing a session
+type BlueskySession struct {
+	AccessJwt  string `json:"accessJwt"`
+	RefreshJwt string `json:"refreshJwt"`
+	Did        string `json:"did"`
+	Handle     string `json:"handle"`
+}
+
+// Authorize returns a session for the account.
+// If a refresh token is passed, the session is refreshed. Otherwise, or if the refresh token has expired, the app password is used to log in.
+// Refresh tokens can only be used once, so the refresh token of the returned session should be stored for next time.
+func (b Bluesky) Authorize(appPassword string, refreshToken string) (BlueskySession, error) {
+	client := resty.New().SetBaseURL(strings.TrimSuffix(b.Service, "/"))
+	var session BlueskySession
+	if refreshToken != "" {
+		resp, err := client.R().
+			SetAuthToken(refreshToken).
+			SetResult(&session).
+			Post("/xrpc/com.atproto.server.refreshSession")
+		if err != nil {
+			return BlueskySession{}, err
+		}
+		if resp.StatusCode() == 200 {
+			log.Debug("Refreshed Bluesky session", "did", session.Did)
+			return session, nil
+		}
+		log.Warn("Failed to refresh Bluesky session. Logging in with the app password", "response", resp.String())
+	}
+	if appPassword == "" {
+		return BlueskySession{}, errors.New("a Bluesky app password is required")
+	}
+	resp, err := client.R().
+		SetBody(map[string]string{
+			"identifier": b.Handle,
+			"password":   appPassword,
+		}).
+		SetResult(&session).
+		Post("/xrpc/com.atproto.server.createSession")
+	if err != nil {
+		return BlueskySession{}, err
+	}
+	if resp.StatusCode() != 200 {
+		return BlueskySession{}, fmt.Errorf("failed to log in to Bluesky: %s", resp.String())
+	}
+	log.Debug("Logged in to Bluesky", "did", session.Did)
+	return session, nil
+}
+
+// Push creates a post announcing the post, with a link card if it has a canonical URL. Drafts aren't announced.
+func (b Bluesky) Push(data PostData, options PushPullOptions) error {
+	if data.Draft {
+		log.Info("Skipping draft since it can't be announced yet", "title", data.Title, "destination", b.Name)
+		return nil
+	}
+	if options.BlueskyAccessToken == "" || options.BlueskyDid == "" {
+		return errors.New("a Bluesky session is required")
+	}
+	defer b.PostIds.Lock(b.Name, data)()
+	announced, err := b.PostIds.Get(b.Name, data)
+	if err != nil {
+		return err
+	}
+	if announced != "" {
+		log.Info("Skipping post that was already announced", "title", data.Title, "destination", b.Name, "uri", announced)
+		return nil
+	}
+	client := resty.New().SetBaseURL(strings.TrimSuffix(b.Service, "/")).SetAuthToken(options.BlueskyAccessToken)
+
+	text, err := renderAnnouncement(b.Template, data, blueskyCharacterLimit, utf8.RuneCountInString)
+	if err != nil {
+		return err
+	}
+	record := map[string]interface{}{
+		"$type":     "app.bsky.feed.post",
+		"text":      text,
+		"createdAt": time.Now().UTC().Format(time.RFC3339),
+	}
+	if facets := blueskyFacets(text); len(facets) > 0 {
+		record["facets"] = facets
+	}
+	if len(b.Languages) > 0 {
+		record["langs"] = b.Languages
+	}
+	if data.CanonicalUrl != "" {
+		external := map[string]interface{}{
+			"uri":         data.CanonicalUrl,
+			"title":       data.Title,
+			"description": data.Description,
+		}
+		// The card still works without a thumbnail, so failing to upload one isn't fatal
+		if thumbnail := firstImage(data.Html); thumbnail != "" {
+			blob, err := b.uploadBlob(client, thumbnail)
+			if err != nil {
+				log.Warn("Failed to upload thumbnail for link card", "image", thumbnail, "error", err)
+			} else {
+				external["thumb"] = blob
+			}
+		}
+		record["embed"] = map[string]interface{}{
+			"$type":    "app.bsky.embed.external",
+			"external": external,
+		}
+	}
+
+	resp, err := client.R().
+		SetBody(map[string]interface{}{
+			"repo":       options.BlueskyDid,
+			"collection": "app.bsky.feed.post",
+			"record":     record,
+		}).
+		SetResult(&map[string]interface{}{}).
+		Post("/xrpc/com.atproto.repo.createRecord")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() != 200 {
+		return fmt.Errorf("failed to create Bluesky post: %s", resp.String())
+	}
+	result := *resp.Result().(*map[string]interface{})
+	uri, _ := result["uri"].(string)
+	log.Info("Posted to Bluesky", "destination", b.Name, "uri", uri)
+	if err := b.PostIds.Set(b.Name, data, uri); err != nil {
+		return fmt.Errorf("created Bluesky post %s but failed to record it in %s, so the post may be announced again: %w", uri, b.PostIds.File, err)
+	}
+	return nil
+}
+
+// Download an image and upload it as a blob, returning the blob to reference in a record
+func (b Bluesky) uploadBlob(client *resty.Client, imageUrl string) (interface{}, error) {
+	image, err := resty.New().R().Get(imageUrl)
+	if err != nil {
+		return nil, err
+	}
+	if image.StatusCode() != 200 {
+		return nil, fmt.Errorf("failed to download image: %s", image.Status())
+	}
+	if len(image.Body()) > blueskyMaxThumbnailSize {
+		return nil, fmt.Errorf("image is %d bytes, which is over the limit of %d", len(image.Body()), blueskyMaxThumbnailSize)
+	}
+	contentType := image.Header().Get("Content-Type")
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType = http.DetectContentType(image.Body())
+	}
+	var result struct {
+		Blob interface{} `json:"blob"`
+	}
+	resp, err := client.R().
+		SetHeader("Content-Type", contentType).
+		SetBody(image.Body()).
+		SetResult(&result).
+		Post("/xrpc/com.atproto.repo.uploadBlob")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != 200 || result.Blob == nil {
+		return nil, fmt.Errorf("failed to upload blob: %s", resp.String())
+	}
+	return result.Blob, nil
+}
+
+// Return the URL of the first image in the HTML with an absolute URL, or an empty string
+func firstImage(html string) string {
+	document, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return ""
+	}
+	var src string
+	document.Find("img[src]").EachWithBreak(func(_ int, img *goquery.Selection) bool {
+		src, _ = img.Attr("src")
+		return !(strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://"))
+	})
+	if !(strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://")) {
+		return ""
+	}
+	return src
+}
+
+// Matches hashtags, which must start at the beginning of the text or after whitespace
+var blueskyHashtagRegex = regexp.MustCompile(`(?:^|\s)(#[^\s#[:punct:]][^\s#]*)`)
+
+// Return the facets for the links and hashtags in the text.
+// Facets refer to the text by UTF-8 byte offsets.
+func blueskyFacets(text string) []map[string]interface{} {
+	facets := []map[string]interface{}{}
+	facet := func(start, end int, feature map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"index":    map[string]int{"byteStart": start, "byteEnd": end},
+			"features": []map[string]interface{}{feature},
+		}
+	}
+	for _, match := range announcementLinkRegex.FindAllStringIndex(text, -1) {
+		facets = append(facets, facet(match[0], match[1], map[string]interface{}{
+			"$type": "app.bsky.richtext.facet#link",
+			"uri":   text[match[0]:match[1]],
+		}))
+	}
+	for _, match := range blueskyHashtagRegex.FindAllStringSubmatchIndex(text, -1) {
+		// Trailing punctuation isn't part of the hashtag
+		tag := strings.TrimRight(text[match[2]:match[3]], `.,;:!?"')]`)
+		facets = append(facets, facet(match[2], match[2]+len(tag), map[string]interface{}{
+			"$type": "app.bsky.richtext.facet#tag",
+			"tag":   strings.TrimPrefix(tag, "#"),
+		}))
+	}
+	return facets
+}
@@ -0,0 +1,89 @@
+package platforms
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBlueskyFacets(t *testing.T) {
+	// A facet's type, byte offsets, and its link or tag
+	type want struct {
+		kind       string
+		start, end int
+		value      string
+	}
+	tests := []struct {
+		name string
+		text string
+		want []want
+	}{
+		{
+			name: "no facets",
+			text: "Just some text",
+			want: []want{},
+		},
+		{
+			name: "ascii",
+			text: "Read https://example.com/post #go",
+			want: []want{
+				{kind: "link", start: 5, end: 29, value: "https://example.com/post"},
+				{kind: "tag", start: 30, end: 33, value: "go"},
+			},
+		},
+		{
+			// "é" is 2 bytes and "☕" is 3 bytes, so the offsets are further along than the character count
+			name: "non-ascii text before the facets",
+			text: "Café ☕ https://example.com/x #go!",
+			want: []want{
+				{kind: "link", start: 10, end: 31, value: "https://example.com/x"},
+				{kind: "tag", start: 32, end: 35, value: "go"},
+			},
+		},
+		{
+			name: "non-ascii hashtag",
+			text: "Über #Straße, done",
+			want: []want{
+				{kind: "tag", start: 6, end: 14, value: "Straße"},
+			},
+		},
+		{
+			name: "trailing punctuation isn't part of a link",
+			text: "(see https://example.com/ü).",
+			want: []want{
+				{kind: "link", start: 5, end: 27, value: "https://example.com/ü"},
+			},
+		},
+		{
+			name: "a # inside a word isn't a hashtag",
+			text: "issue#12 and C#",
+			want: []want{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []want{}
+			for _, facet := range blueskyFacets(tt.text) {
+				index := facet["index"].(map[string]int)
+				feature := facet["features"].([]map[string]interface{})[0]
+				w := want{start: index["byteStart"], end: index["byteEnd"]}
+				switch feature["$type"] {
+				case "app.bsky.richtext.facet#link":
+					w.kind, w.value = "link", feature["uri"].(string)
+				case "app.bsky.richtext.facet#tag":
+					w.kind, w.value = "tag", feature["tag"].(string)
+				}
+				got = append(got, w)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("blueskyFacets(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+			// The offsets should cover exactly the link, or the tag with its #
+			for _, w := range got {
+				covered := tt.text[w.start:w.end]
+				if (w.kind == "link" && covered != w.value) || (w.kind == "tag" && covered != "#"+w.value) {
+					t.Errorf("bytes %d to %d of %q are %q, which doesn't match %s %q", w.start, w.end, tt.text, covered, w.kind, w.value)
+				}
+			}
+		})
+	}
+}
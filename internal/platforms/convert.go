@@ -0,0 +1,66 @@
+package platforms
+
+import (
+	"regexp"
+	"strings"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Match classes used to mark the language of a code block, such as "language-go", "lang-go" (used by Prettify), or "brush: go" (used by SyntaxHighlighter)
+var codeLanguageRegex = regexp.MustCompile(`(?:^|\s)(?:language-|lang-|brush:\s*)([\w+#.-]+)`)
+
+// Convert HTML to Markdown.
+// Embeds matching the rules are turned into shortcodes and code blocks are fenced with their language, if it can be detected.
+func HtmlToMarkdown(html string, embeds []EmbedRule) (string, error) {
+	html, shortcodes := replaceEmbedsWithPlaceholders(html, embeds)
+	converter := md.NewConverter("", true, nil)
+	converter.AddRules(md.Rule{
+		Filter:      []string{"pre"},
+		Replacement: fencedCodeBlock,
+	})
+	markdown, err := converter.ConvertString(html)
+	if err != nil {
+		return "", err
+	}
+	for placeholder, shortcode := range shortcodes {
+		markdown = strings.ReplaceAll(markdown, placeholder, shortcode)
+	}
+	return markdown, nil
+}
+
+// Convert a <pre> element to a fenced code block, detecting the language from its classes or those of its <code> element
+func fencedCodeBlock(content string, selec *goquery.Selection, opt *md.Options) *string {
+	language := codeBlockLanguage(selec.Find("code").AttrOr("class", ""))
+	if language == "" {
+		language = codeBlockLanguage(selec.AttrOr("class", ""))
+	}
+	// Blogger's editor uses <br> for line breaks inside code blocks
+	selec.Find("br").ReplaceWithHtml("\n")
+	code := strings.TrimSuffix(selec.Text(), "\n")
+
+	// The fence has to be longer than any run of backticks in the code
+	longestRun, run := 0, 0
+	for _, r := range code {
+		if r == '`' {
+			run++
+			if run > longestRun {
+				longestRun = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longestRun+1))
+	text := "\n\n" + fence + language + "\n" + code + "\n" + fence + "\n\n"
+	return &text
+}
+
+func codeBlockLanguage(class string) string {
+	match := codeLanguageRegex.FindStringSubmatch(class)
+	if match == nil {
+		return ""
+	}
+	return strings.ToLower(match[1])
+}
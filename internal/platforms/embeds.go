@@ -0,0 +1,177 @@
+package platforms
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// EmbedRule maps an HTML embed (such as a YouTube iframe) to a Hugo shortcode and back.
+// Templates use the named capture groups of the matching pattern, such as ${id}.
+type EmbedRule struct {
+	Name string
+	// HtmlPattern matches the embed in HTML and Shortcode is what it's replaced with in Markdown
+	HtmlPattern *regexp.Regexp
+	Shortcode   string
+	// ShortcodePattern matches the shortcode in Markdown and Html is what it's replaced with in HTML
+	ShortcodePattern *regexp.Regexp
+	Html             string
+}
+
+// DefaultEmbedRules are used when shortcodes is enabled. They cover the embeds Hugo has built-in shortcodes for.
+var DefaultEmbedRules = []EmbedRule{
+	{
+		Name:             "youtube",
+		HtmlPattern:      regexp.MustCompile(`<iframe[^>]*\ssrc="(?:https?:)?//(?:www\.)?youtube(?:-nocookie)?\.com/embed/(?P<id>[\w-]+)[^"]*"[^>]*>\s*</iframe>`),
+		Shortcode:        `{{< youtube ${id} >}}`,
+		ShortcodePattern: regexp.MustCompile(`\{\{<\s*youtube\s+(?:id=)?"?(?P<id>[\w-]+)"?\s*>\}\}`),
+		Html:             `<iframe width="560" height="315" src="https://www.youtube.com/embed/${id}" frameborder="0" allowfullscreen></iframe>`,
+	},
+	{
+		Name:             "vimeo",
+		HtmlPattern:      regexp.MustCompile(`<iframe[^>]*\ssrc="(?:https?:)?//player\.vimeo\.com/video/(?P<id>\d+)[^"]*"[^>]*>\s*</iframe>`),
+		Shortcode:        `{{< vimeo ${id} >}}`,
+		ShortcodePattern: regexp.MustCompile(`\{\{<\s*vimeo\s+(?:id=)?"?(?P<id>\d+)"?\s*>\}\}`),
+		Html:             `<iframe width="640" height="360" src="https://player.vimeo.com/video/${id}" frameborder="0" allowfullscreen></iframe>`,
+	},
+	{
+		Name:             "gist",
+		HtmlPattern:      regexp.MustCompile(`<script[^>]*\ssrc="https://gist\.github\.com/(?P<user>[\w-]+)/(?P<id>[0-9a-f]+)\.js"[^>]*>\s*</script>`),
+		Shortcode:        `{{< gist ${user} ${id} >}}`,
+		ShortcodePattern: regexp.MustCompile(`\{\{<\s*gist\s+(?P<user>[\w-]+)\s+(?P<id>[0-9a-f]+)\s*>\}\}`),
+		Html:             `<script src="https://gist.github.com/${user}/${id}.js"></script>`,
+	},
+	{
+		Name:             "tweet",
+		HtmlPattern:      regexp.MustCompile(`(?s)<blockquote[^>]*class="twitter-tweet"[^>]*>.*?https?://(?:twitter|x)\.com/(?P<user>\w+)/status/(?P<id>\d+).*?</blockquote>(?:\s*<script[^>]*platform\.twitter\.com/widgets\.js[^>]*>\s*</script>)?`),
+		Shortcode:        `{{< tweet user="${user}" id="${id}" >}}`,
+		ShortcodePattern: regexp.MustCompile(`\{\{<\s*tweet\s+user="(?P<user>\w+)"\s+id="(?P<id>\d+)"\s*>\}\}`),
+		Html:             `<blockquote class="twitter-tweet"><a href="https://twitter.com/${user}/status/${id}"></a></blockquote><script async src="https://platform.twitter.com/widgets.js"></script>`,
+	},
+}
+
+// Return the embed rules for a platform. If shortcodes is true, the default rules are included.
+// embedRules is the embed_rules key from the config (interface{} due to how Viper works) and is added after the defaults.
+func EmbedRulesFromInterface(shortcodes bool, embedRules interface{}) ([]EmbedRule, error) {
+	rules := []EmbedRule{}
+	if shortcodes {
+		rules = append(rules, DefaultEmbedRules...)
+	}
+	if embedRules == nil {
+		return rules, nil
+	}
+	ruleSlice, ok := embedRules.([]interface{})
+	if !ok {
+		return nil, errors.New("embed_rules is not a list")
+	}
+	for i, r := range ruleSlice {
+		ruleMap, ok := r.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("embed rule %d is not a table", i+1)
+		}
+		rule := EmbedRule{}
+		rule.Name, _ = ruleMap["name"].(string)
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("embed rule %d", i+1)
+		}
+		// Either direction can be left out, but at least one is required
+		htmlPattern, _ := ruleMap["html_pattern"].(string)
+		rule.Shortcode, _ = ruleMap["shortcode"].(string)
+		shortcodePattern, _ := ruleMap["shortcode_pattern"].(string)
+		rule.Html, _ = ruleMap["html"].(string)
+		if (htmlPattern == "") != (rule.Shortcode == "") {
+			return nil, fmt.Errorf("%s: html_pattern and shortcode must be set together", rule.Name)
+		}
+		if (shortcodePattern == "") != (rule.Html == "") {
+			return nil, fmt.Errorf("%s: shortcode_pattern and html must be set together", rule.Name)
+		}
+		if htmlPattern == "" && shortcodePattern == "" {
+			return nil, fmt.Errorf("%s: html_pattern or shortcode_pattern is required", rule.Name)
+		}
+		var err error
+		if htmlPattern != "" {
+			rule.HtmlPattern, err = regexp.Compile(htmlPattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", rule.Name, err)
+			}
+		}
+		if shortcodePattern != "" {
+			rule.ShortcodePattern, err = regexp.Compile(shortcodePattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", rule.Name, err)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Expand a template such as "{{< youtube ${id} >}}" with the named groups from a match
+func expandEmbedTemplate(pattern *regexp.Regexp, template string, content string, match []int) string {
+	return string(pattern.ExpandString(nil, template, content, match))
+}
+
+// Replace embeds in HTML with placeholders, returning the new HTML and the shortcode for each placeholder.
+// Placeholders are used rather than the shortcodes themselves so the HTML to Markdown converter doesn't escape them.
+func replaceEmbedsWithPlaceholders(content string, rules []EmbedRule) (string, map[string]string) {
+	shortcodes := map[string]string{}
+	for _, rule := range rules {
+		if rule.HtmlPattern == nil {
+			continue
+		}
+		content = replaceAllSubmatchFunc(rule.HtmlPattern, content, func(match []int) string {
+			placeholder := fmt.Sprintf("CROSSBLOGGEREMBED%d", len(shortcodes))
+			shortcodes[placeholder] = expandEmbedTemplate(rule.HtmlPattern, rule.Shortcode, content, match)
+			// Wrap the placeholder in a paragraph so the shortcode ends up on its own line
+			return "<p>" + placeholder + "</p>"
+		})
+	}
+	return content, shortcodes
+}
+
+// Match shortcodes in rendered HTML, where the angle brackets may have been escaped
+var renderedShortcodeRegex = regexp.MustCompile(`(?:<p>\s*)?\{\{(?:<|&lt;)(.*?)(?:>|&gt;)\}\}(?:\s*</p>)?`)
+
+var smartQuoteReplacer = strings.NewReplacer("“", `"`, "”", `"`, "„", `"`, "‘", "'", "’", "'")
+
+// ExpandShortcodes replaces shortcodes in HTML with the HTML of the matching embed rule.
+// Shortcodes that don't match any rule are left as is.
+func ExpandShortcodes(content string, rules []EmbedRule) string {
+	return renderedShortcodeRegex.ReplaceAllStringFunc(content, func(match string) string {
+		inner := renderedShortcodeRegex.FindStringSubmatch(match)[1]
+		// Undo any escaping and smart quotes from rendering the Markdown
+		shortcode := "{{<" + smartQuoteReplacer.Replace(html.UnescapeString(inner)) + ">}}"
+		for _, rule := range rules {
+			if rule.ShortcodePattern == nil {
+				continue
+			}
+			if loc := rule.ShortcodePattern.FindStringSubmatchIndex(shortcode); loc != nil {
+				expanded := expandEmbedTemplate(rule.ShortcodePattern, rule.Html, shortcode, loc)
+				// Keep the paragraph around the shortcode if it wasn't the only thing in the paragraph
+				if strings.HasPrefix(match, "<p>") && !strings.HasSuffix(match, "</p>") {
+					return "<p>" + expanded
+				}
+				if !strings.HasPrefix(match, "<p>") && strings.HasSuffix(match, "</p>") {
+					return expanded + "</p>"
+				}
+				return expanded
+			}
+		}
+		return match
+	})
+}
+
+// Like regexp.ReplaceAllStringFunc, but the function is passed the submatch indices so named groups can be expanded
+func replaceAllSubmatchFunc(pattern *regexp.Regexp, content string, replace func(match []int) string) string {
+	var result strings.Builder
+	last := 0
+	for _, match := range pattern.FindAllStringSubmatchIndex(content, -1) {
+		result.WriteString(content[last:match[0]])
+		result.WriteString(replace(match))
+		last = match[1]
+	}
+	result.WriteString(content[last:])
+	return result.String()
+}
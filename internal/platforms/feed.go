@@ -0,0 +1,418 @@
+package platforms
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gosimple/slug"
+)
+
+// Feed maintains an RSS 2.0, Atom, or JSON Feed file with an entry for each pushed post.
+// Pushing a post that's already in the feed updates its entry. Only the newest MaxItems entries are kept.
+// Drafts aren't added.
+type Feed struct {
+	Name string
+	File string
+	// Format is "rss", "atom", or "json"
+	Format      string
+	Title       string
+	Description string
+	// Link is the URL of the site the feed is for and FeedUrl is the URL the feed itself is served at
+	Link    string
+	FeedUrl string
+	// MaxItems is the number of entries to keep. If 0, every entry is kept.
+	MaxItems int
+	// If GitDir is set, the feed file is committed and pushed the same way as Markdown posts
+	GitDir string
+	Git    GitOptions
+	Transforms
+	PushHooks
+}
+
+func (f Feed) GetName() string { return f.Name }
+func (f Feed) GetType() string { return "feed" }
+
+// The default commit message for feeds, since the default for Markdown names the post's file
+const DefaultFeedCommitMessage = `Update feed with "{{.Post.Title}}"`
+
+// feedItem is an entry in the feed, independent of the format
+type feedItem struct {
+	Id         string
+	Url        string
+	Title      string
+	Html       string
+	Summary    string
+	Author     string
+	Published  time.Time
+	Updated    time.Time
+	Categories []string
+}
+
+// Pushing rewrites the whole file, so only one post is pushed at a time
+var feedWriteLock sync.Mutex
+
+// Push adds the post to the feed or, if it's already in the feed, updates its entry
+func (f Feed) Push(data PostData, options PushPullOptions) error {
+	// Feeds are public, so drafts are left out
+	if data.Draft {
+		log.Info("Skipping draft since feeds only have published posts", "title", data.Title, "feed", f.Name)
+		return nil
+	}
+	feedWriteLock.Lock()
+	defer feedWriteLock.Unlock()
+	postSlug := slug.Make(data.Title)
+	// Reuse the Markdown destination's Git logic, with the feed's directory as the content directory
+	repo := Markdown{Name: f.Name, ContentDir: filepath.Dir(f.File), GitDir: f.GitDir, Git: f.Git}
+	var baseBranch string
+	if f.GitDir != "" {
+		var err error
+		baseBranch, err = repo.PrepareGit(postSlug, options)
+		if err != nil {
+			return err
+		}
+	}
+
+	items, err := f.read()
+	if err != nil {
+		return err
+	}
+	item := feedItemFromPostData(data, postSlug)
+	replaced := false
+	for i, existing := range items {
+		if existing.Id == item.Id {
+			items[i] = item
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		items = append(items, item)
+	}
+	// Newest first, then drop the oldest entries
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Published.After(items[j].Published) })
+	if f.MaxItems > 0 && len(items) > f.MaxItems {
+		items = items[:f.MaxItems]
+	}
+	if err := f.write(items); err != nil {
+		return err
+	}
+	log.Debug("Updated feed", "file", f.File, "entries", len(items), "replaced", replaced)
+
+	if f.GitDir != "" {
+		commitHash, err := repo.Commit(GitChange{Slug: postSlug, Path: filepath.Base(f.File), Post: data, Base: baseBranch}, true, options)
+		if err != nil {
+			return err
+		}
+		log.Info("Committed and pushed changes", "hash", commitHash)
+	}
+	return nil
+}
+
+// Convert PostData into a feed entry. The canonical URL identifies the entry, falling back to the slug.
+func feedItemFromPostData(data PostData, postSlug string) feedItem {
+	item := feedItem{
+		Id:         data.CanonicalUrl,
+		Url:        data.CanonicalUrl,
+		Title:      data.Title,
+		Html:       data.Html,
+		Summary:    data.Description,
+		Author:     data.Author,
+		Published:  data.Date,
+		Updated:    data.DateUpdated,
+		Categories: append(append([]string{}, data.Categories...), data.Tags...),
+	}
+	if item.Id == "" {
+		item.Id = "urn:cross-blogger:" + postSlug
+	}
+	if item.Published.IsZero() {
+		item.Published = time.Now()
+	}
+	if item.Updated.IsZero() {
+		item.Updated = item.Published
+	}
+	return item
+}
+
+// Types for reading the feed back. Elements are matched by local name.
+type rssFeed struct {
+	Items []struct {
+		Title       string   `xml:"title"`
+		Link        string   `xml:"link"`
+		Guid        string   `xml:"guid"`
+		PubDate     string   `xml:"pubDate"`
+		Description string   `xml:"description"`
+		Encoded     string   `xml:"encoded"`
+		Creator     string   `xml:"creator"`
+		Categories  []string `xml:"category"`
+	} `xml:"channel>item"`
+}
+
+type atomFeed struct {
+	Entries []struct {
+		Id         string         `xml:"id"`
+		Title      string         `xml:"title"`
+		Links      []atomLink     `xml:"link"`
+		Published  string         `xml:"published"`
+		Updated    string         `xml:"updated"`
+		Summary    string         `xml:"summary"`
+		Content    string         `xml:"content"`
+		Author     atomAuthor     `xml:"author"`
+		Categories []atomCategory `xml:"category"`
+	} `xml:"entry"`
+}
+
+// https://www.jsonfeed.org/version/1.1/
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageUrl string         `json:"home_page_url,omitempty"`
+	FeedUrl     string         `json:"feed_url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	Id            string           `json:"id"`
+	Url           string           `json:"url,omitempty"`
+	Title         string           `json:"title,omitempty"`
+	ContentHtml   string           `json:"content_html"`
+	Summary       string           `json:"summary,omitempty"`
+	DatePublished string           `json:"date_published,omitempty"`
+	DateModified  string           `json:"date_modified,omitempty"`
+	Tags          []string         `json:"tags,omitempty"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+// Read the entries in the feed file. If it doesn't exist yet, there are no entries.
+func (f Feed) read() ([]feedItem, error) {
+	data, err := os.ReadFile(f.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return []feedItem{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	items := []feedItem{}
+	switch f.Format {
+	case "rss":
+		var feed rssFeed
+		if err := xml.Unmarshal(data, &feed); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", f.File, err)
+		}
+		for _, entry := range feed.Items {
+			published, _ := time.Parse(time.RFC1123Z, entry.PubDate)
+			items = append(items, feedItem{
+				Id:         entry.Guid,
+				Url:        entry.Link,
+				Title:      entry.Title,
+				Html:       entry.Encoded,
+				Summary:    entry.Description,
+				Author:     entry.Creator,
+				Published:  published,
+				Updated:    published,
+				Categories: entry.Categories,
+			})
+		}
+	case "atom":
+		var feed atomFeed
+		if err := xml.Unmarshal(data, &feed); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", f.File, err)
+		}
+		for _, entry := range feed.Entries {
+			published, _ := time.Parse(time.RFC3339, entry.Published)
+			updated, _ := time.Parse(time.RFC3339, entry.Updated)
+			item := feedItem{
+				Id:        entry.Id,
+				Url:       alternateLink(entry.Links),
+				Title:     entry.Title,
+				Html:      entry.Content,
+				Summary:   entry.Summary,
+				Author:    entry.Author.Name,
+				Published: published,
+				Updated:   updated,
+			}
+			for _, category := range entry.Categories {
+				item.Categories = append(item.Categories, category.Term)
+			}
+			items = append(items, item)
+		}
+	case "json":
+		var feed jsonFeed
+		if err := json.Unmarshal(data, &feed); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", f.File, err)
+		}
+		for _, entry := range feed.Items {
+			published, _ := time.Parse(time.RFC3339, entry.DatePublished)
+			updated, _ := time.Parse(time.RFC3339, entry.DateModified)
+			item := feedItem{
+				Id:         entry.Id,
+				Url:        entry.Url,
+				Title:      entry.Title,
+				Html:       entry.ContentHtml,
+				Summary:    entry.Summary,
+				Published:  published,
+				Updated:    updated,
+				Categories: entry.Tags,
+			}
+			if len(entry.Authors) > 0 {
+				item.Author = entry.Authors[0].Name
+			}
+			items = append(items, item)
+		}
+	default:
+		return nil, fmt.Errorf("unknown feed format: %s", f.Format)
+	}
+	return items, nil
+}
+
+// The data passed to the RSS and Atom templates
+type feedTemplateData struct {
+	Feed
+	Items []feedItem
+	// Updated is when the newest entry was updated
+	Updated time.Time
+}
+
+// Write the entries to the feed file
+func (f Feed) write(items []feedItem) error {
+	if err := os.MkdirAll(filepath.Dir(f.File), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomically(f.File, func(file io.Writer) error {
+		switch f.Format {
+		case "rss", "atom":
+			data := feedTemplateData{Feed: f, Items: items, Updated: time.Now()}
+			for i, item := range items {
+				if i == 0 || item.Updated.After(data.Updated) {
+					data.Updated = item.Updated
+				}
+			}
+			if f.Format == "rss" {
+				return rssTemplate.Execute(file, data)
+			}
+			return atomTemplate.Execute(file, data)
+		case "json":
+			feed := jsonFeed{
+				Version:     "https://jsonfeed.org/version/1.1",
+				Title:       f.Title,
+				HomePageUrl: f.Link,
+				FeedUrl:     f.FeedUrl,
+				Description: f.Description,
+				Items:       []jsonFeedItem{},
+			}
+			for _, item := range items {
+				entry := jsonFeedItem{
+					Id:            item.Id,
+					Url:           item.Url,
+					Title:         item.Title,
+					ContentHtml:   item.Html,
+					Summary:       item.Summary,
+					DatePublished: item.Published.Format(time.RFC3339),
+					DateModified:  item.Updated.Format(time.RFC3339),
+					Tags:          item.Categories,
+				}
+				if item.Author != "" {
+					entry.Authors = []jsonFeedAuthor{{Name: item.Author}}
+				}
+				feed.Items = append(feed.Items, entry)
+			}
+			encoder := json.NewEncoder(file)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(feed)
+		default:
+			return fmt.Errorf("unknown feed format: %s", f.Format)
+		}
+	})
+}
+
+var feedTemplateFuncs = template.FuncMap{
+	"xml":     wxrEscape,
+	"cdata":   wxrCdata,
+	"rfc1123": func(t time.Time) string { return t.Format(time.RFC1123Z) },
+	"rfc3339": func(t time.Time) string { return t.Format(time.RFC3339) },
+	"isUrl":   func(s string) bool { return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") },
+}
+
+var rssTemplate = template.Must(template.New("rss").Funcs(feedTemplateFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:atom="http://www.w3.org/2005/Atom">
+<channel>
+	<title>{{xml .Title}}</title>
+	<link>{{xml .Link}}</link>
+	<description>{{xml .Description}}</description>
+{{- if .FeedUrl}}
+	<atom:link href="{{xml .FeedUrl}}" rel="self" type="application/rss+xml"/>
+{{- end}}
+	<lastBuildDate>{{rfc1123 .Updated}}</lastBuildDate>
+	<generator>cross-blogger</generator>
+{{- range .Items}}
+	<item>
+		<title>{{xml .Title}}</title>
+{{- if .Url}}
+		<link>{{xml .Url}}</link>
+{{- end}}
+		<guid isPermaLink="{{if isUrl .Id}}true{{else}}false{{end}}">{{xml .Id}}</guid>
+		<pubDate>{{rfc1123 .Published}}</pubDate>
+{{- if .Author}}
+		<dc:creator>{{xml .Author}}</dc:creator>
+{{- end}}
+{{- range .Categories}}
+		<category>{{xml .}}</category>
+{{- end}}
+		<description>{{xml .Summary}}</description>
+		<content:encoded>{{cdata .Html}}</content:encoded>
+	</item>
+{{- end}}
+</channel>
+</rss>
+`))
+
+var atomTemplate = template.Must(template.New("atom").Funcs(feedTemplateFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<id>{{xml (or .FeedUrl .Link)}}</id>
+	<title>{{xml .Title}}</title>
+{{- if .Description}}
+	<subtitle>{{xml .Description}}</subtitle>
+{{- end}}
+	<link href="{{xml .Link}}" rel="alternate" type="text/html"/>
+{{- if .FeedUrl}}
+	<link href="{{xml .FeedUrl}}" rel="self" type="application/atom+xml"/>
+{{- end}}
+	<updated>{{rfc3339 .Updated}}</updated>
+	<generator>cross-blogger</generator>
+{{- range .Items}}
+	<entry>
+		<id>{{xml .Id}}</id>
+		<title>{{xml .Title}}</title>
+{{- if .Url}}
+		<link href="{{xml .Url}}" rel="alternate" type="text/html"/>
+{{- end}}
+		<published>{{rfc3339 .Published}}</published>
+		<updated>{{rfc3339 .Updated}}</updated>
+{{- if .Author}}
+		<author><name>{{xml .Author}}</name></author>
+{{- end}}
+{{- range .Categories}}
+		<category term="{{xml .}}"/>
+{{- end}}
+{{- if .Summary}}
+		<summary>{{xml .Summary}}</summary>
+{{- end}}
+		<content type="html">{{xml .Html}}</content>
+	</entry>
+{{- end}}
+</feed>
+`))
@@ -15,6 +15,8 @@ var FrontMatterMappings = map[string]string{
 	"categories":    "categories",
 	"tags":          "tags",
 	"canonical_url": "canonicalURL",
+	"draft":         "draft",
+	"author":        "author",
 	"managed":       "managedByCrossBlogger"}
 
 type Frontmatter struct {
@@ -26,6 +28,8 @@ type Frontmatter struct {
 	Categories   []string
 	Tags         []string
 	CanonicalUrl string
+	Draft        bool
+	Author       string
 	Managed      bool
 }
 
@@ -37,6 +41,8 @@ type FrontmatterMapping struct {
 	Categories   string
 	Tags         string
 	CanonicalURL string
+	Draft        string
+	Author       string
 	Managed      string
 }
 
@@ -71,6 +77,13 @@ func (f *Frontmatter) ToMap(frontmatterMapping FrontmatterMapping) map[string]in
 	if f.CanonicalUrl != "" && frontmatterMapping.CanonicalURL != "" {
 		frontmatterAsMap[frontmatterMapping.CanonicalURL] = f.CanonicalUrl
 	}
+	if f.Author != "" && frontmatterMapping.Author != "" {
+		frontmatterAsMap[frontmatterMapping.Author] = f.Author
+	}
+	// Only drafts get the key, since posts are published by default
+	if f.Draft && frontmatterMapping.Draft != "" {
+		frontmatterAsMap[frontmatterMapping.Draft] = f.Draft
+	}
 	if frontmatterMapping.Managed != "" {
 		frontmatterAsMap[frontmatterMapping.Managed] = f.Managed
 	}
@@ -105,6 +118,8 @@ func FrontmatterMappingFromInterface(m interface{}) (*FrontmatterMapping, error)
 		Description:  frontmatterMapping["description"].(string),
 		Categories:   frontmatterMapping["categories"].(string),
 		Tags:         frontmatterMapping["tags"].(string),
+		Draft:        frontmatterMapping["draft"].(string),
+		Author:       frontmatterMapping["author"].(string),
 		Managed:      frontmatterMapping["managed"].(string),
 	}, nil
 }
@@ -184,6 +199,12 @@ func FrontmatterFromMap(m map[string]interface{}, frontmatterMapping Frontmatter
 	if canonicalURL, ok := m[frontmatterMapping.CanonicalURL]; ok {
 		frontmatterObject.CanonicalUrl = canonicalURL.(string)
 	}
+	if author, ok := m[frontmatterMapping.Author]; ok {
+		frontmatterObject.Author, _ = author.(string)
+	}
+	if draft, ok := m[frontmatterMapping.Draft]; ok {
+		frontmatterObject.Draft, _ = draft.(bool)
+	}
 	if managed, ok := m[frontmatterMapping.Managed]; ok {
 		frontmatterObject.Managed = managed.(bool)
 	}
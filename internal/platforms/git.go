@@ -0,0 +1,432 @@
+package platforms
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/charmbracelet/log"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/slashtechno/cross-blogger/pkg/forge"
+	"github.com/slashtechno/cross-blogger/pkg/utils"
+)
+
+// GitOptions configures how a Markdown destination commits to and pushes its Git repository
+type GitOptions struct {
+	// Remote defaults to "origin"
+	Remote string
+	// Branch is the branch changes are made on (or, in review mode, the branch pull requests target).
+	// If empty, whatever branch is checked out is used.
+	Branch string
+	// Pull is how changes from the remote are brought in before committing: "fast-forward", "rebase", "merge", or "" to not pull
+	Pull string
+	// PostBranches commits each post to its own branch, named BranchPrefix followed by the post's slug
+	PostBranches bool
+	BranchPrefix string
+	// Review pushes the post's branch and opens a pull request instead of committing to Branch. It implies PostBranches.
+	Review bool
+	// Forge is the type of forge ("github" or "gitea") used to open pull requests in review mode
+	Forge string
+	// ForgeUrl is the URL of the forge's API. It defaults to https://api.github.com for GitHub.
+	ForgeUrl string
+	// Repository is the owner and name of the repository on the forge, such as "slashtechno/blog"
+	Repository string
+	// AuthorName and AuthorEmail are used for commits. If unset, the repository's Git config is used.
+	AuthorName  string
+	AuthorEmail string
+	// SshKey is the path to a private key used to pull and push. Its passphrase is read from the credentials file.
+	SshKey string
+	// HttpsUsername is used with the HTTPS token from the credentials file. It defaults to "git".
+	HttpsUsername string
+	// Sign is how commits are signed: "gpg", "ssh", or "" to not sign them. SigningKey is the path to the key.
+	Sign       string
+	SigningKey string
+	// CommitMessage is a Go template for commit messages, executed with a GitChange
+	CommitMessage *template.Template
+	// Batch commits and pushes once per run (or per tick when watching) instead of once per post
+	Batch bool
+	// BatchCommitMessage is a Go template for the commit message of batches, executed with a GitBatchSummary
+	BatchCommitMessage *template.Template
+}
+
+// GitChange is a change to a post's file that is committed to the repository
+type GitChange struct {
+	Slug string
+	// Path is the file that changed, relative to ContentDir. If empty, it's the post's file (Slug followed by .md).
+	Path string
+	// Post is the post that was pushed or, for deletions, what's known about it from its frontmatter
+	Post    PostData
+	Deleted bool
+	// Base is the branch returned by PrepareGit
+	Base string
+}
+
+// Convert the git table of a destination (interface{} due to how Viper works) to a GitOptions struct
+func GitOptionsFromInterface(g interface{}) (GitOptions, error) {
+	gitOptions := GitOptions{
+		Remote:        git.DefaultRemoteName,
+		BranchPrefix:  "cross-blogger/",
+		HttpsUsername: "git",
+		CommitMessage: template.Must(template.New("commit_message").Parse(DefaultCommitMessage)),
+	}
+	gitOptions.BatchCommitMessage = template.Must(parseBatchCommitMessage(""))
+	if g == nil {
+		return gitOptions, nil
+	}
+	gitMap, ok := g.(map[string]interface{})
+	if !ok {
+		return GitOptions{}, errors.New("git is not a table")
+	}
+	if remote, ok := gitMap["remote"].(string); ok && remote != "" {
+		gitOptions.Remote = remote
+	}
+	gitOptions.Branch, _ = gitMap["branch"].(string)
+	gitOptions.Pull, _ = gitMap["pull"].(string)
+	switch gitOptions.Pull {
+	case "", "fast-forward", "rebase", "merge":
+	default:
+		return GitOptions{}, fmt.Errorf("unknown pull strategy: %s", gitOptions.Pull)
+	}
+	gitOptions.PostBranches, _ = gitMap["post_branches"].(bool)
+	if prefix, ok := gitMap["branch_prefix"].(string); ok {
+		gitOptions.BranchPrefix = prefix
+	}
+	gitOptions.Review, _ = gitMap["review"].(bool)
+	gitOptions.Forge, _ = gitMap["forge"].(string)
+	gitOptions.ForgeUrl, _ = gitMap["forge_url"].(string)
+	gitOptions.Repository, _ = gitMap["repository"].(string)
+	if gitOptions.Review {
+		gitOptions.PostBranches = true
+		if gitOptions.Forge == "" || gitOptions.Repository == "" {
+			return GitOptions{}, errors.New("forge and repository are required for review mode")
+		}
+		// Check that the forge type is valid now rather than when the first post is pushed
+		if _, err := forge.New(gitOptions.Forge, gitOptions.ForgeUrl, ""); err != nil {
+			return GitOptions{}, err
+		}
+	}
+	gitOptions.AuthorName, _ = gitMap["author_name"].(string)
+	gitOptions.AuthorEmail, _ = gitMap["author_email"].(string)
+	gitOptions.SshKey, _ = gitMap["ssh_key"].(string)
+	if username, ok := gitMap["https_username"].(string); ok && username != "" {
+		gitOptions.HttpsUsername = username
+	}
+	gitOptions.Sign, _ = gitMap["sign"].(string)
+	gitOptions.SigningKey, _ = gitMap["signing_key"].(string)
+	switch gitOptions.Sign {
+	case "":
+	case "gpg", "ssh":
+		if gitOptions.SigningKey == "" {
+			return GitOptions{}, errors.New("signing_key is required to sign commits")
+		}
+	default:
+		return GitOptions{}, fmt.Errorf("unknown signing method: %s", gitOptions.Sign)
+	}
+	if commitMessage, ok := gitMap["commit_message"].(string); ok && commitMessage != "" {
+		var err error
+		gitOptions.CommitMessage, err = template.New("commit_message").Parse(commitMessage)
+		if err != nil {
+			return GitOptions{}, fmt.Errorf("failed to parse commit_message: %w", err)
+		}
+	}
+	gitOptions.Batch, _ = gitMap["batch"].(bool)
+	if batchCommitMessage, ok := gitMap["batch_commit_message"].(string); ok && batchCommitMessage != "" {
+		var err error
+		gitOptions.BatchCommitMessage, err = parseBatchCommitMessage(batchCommitMessage)
+		if err != nil {
+			return GitOptions{}, fmt.Errorf("failed to parse batch_commit_message: %w", err)
+		}
+	}
+	return gitOptions, nil
+}
+
+// Open the Git repository, checking that the content directory is inside it
+func (m Markdown) openRepository() (*git.Repository, *git.Worktree, error) {
+	// Make sure contentDir and gitDir are absolute paths
+	contentDir, err := filepath.Abs(filepath.Clean(m.ContentDir))
+	if err != nil {
+		return nil, nil, err
+	}
+	gitDir, err := filepath.Abs(filepath.Clean(m.GitDir))
+	if err != nil {
+		return nil, nil, err
+	}
+	// Check if the contentDir is a subdirectory of the gitDir
+	isSubdir, err := utils.IsSubdirectory(gitDir, contentDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !isSubdir {
+		return nil, nil, fmt.Errorf("contentDir is not a subdirectory of gitDir")
+	}
+	repo, err := git.PlainOpen(gitDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, nil, err
+	}
+	return repo, worktree, nil
+}
+
+// Return the short name of the branch that is checked out
+func currentBranch(repo *git.Repository) (string, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return "", err
+	}
+	if !head.Name().IsBranch() {
+		return "", fmt.Errorf("HEAD is detached; check out a branch or set git.branch")
+	}
+	return head.Name().Short(), nil
+}
+
+// PrepareGit gets the repository ready for a change to the post with the given slug. It should be called before the post's file is changed.
+// It switches to the target branch, pulls from the remote, and, if enabled, creates a branch for the post.
+// The returned base branch should be passed to Commit.
+func (m Markdown) PrepareGit(slug string, options PushPullOptions) (base string, err error) {
+	repo, worktree, err := m.openRepository()
+	if err != nil {
+		return "", err
+	}
+	base, err = m.updateBase(repo, worktree, options)
+	if err != nil {
+		return "", err
+	}
+	if m.Git.PostBranches {
+		postBranch := plumbing.NewBranchReferenceName(m.Git.BranchPrefix + slug)
+		// Start the post's branch from the base branch, replacing it if it already exists
+		head, err := repo.Head()
+		if err != nil {
+			return "", err
+		}
+		if err := repo.Storer.SetReference(plumbing.NewHashReference(postBranch, head.Hash())); err != nil {
+			return "", err
+		}
+		if err := worktree.Checkout(&git.CheckoutOptions{Branch: postBranch}); err != nil {
+			return "", err
+		}
+		log.Debug("Checked out post branch", "branch", postBranch.Short(), "base", base)
+	}
+	return base, nil
+}
+
+// UpdateFromRemote checks out the base branch and pulls it with the configured strategy, so a Markdown source reads the latest version of its posts.
+// Unlike PrepareGit, it never creates a post branch. It does nothing if GitDir isn't set.
+func (m Markdown) UpdateFromRemote(options PushPullOptions) error {
+	if m.GitDir == "" {
+		return nil
+	}
+	repo, worktree, err := m.openRepository()
+	if err != nil {
+		return err
+	}
+	_, err = m.updateBase(repo, worktree, options)
+	return err
+}
+
+// Check out the base branch (the configured branch, or whatever is checked out) and pull it, returning its name
+func (m Markdown) updateBase(repo *git.Repository, worktree *git.Worktree, options PushPullOptions) (string, error) {
+	base := m.Git.Branch
+	if base == "" {
+		var err error
+		base, err = currentBranch(repo)
+		if err != nil {
+			return "", err
+		}
+	}
+	if err := m.checkoutBranch(repo, worktree, base); err != nil {
+		return "", err
+	}
+	if err := m.pull(worktree, base, options); err != nil {
+		return "", err
+	}
+	return base, nil
+}
+
+// Check out a branch, creating it from the remote branch of the same name if it doesn't exist locally
+func (m Markdown) checkoutBranch(repo *git.Repository, worktree *git.Worktree, branch string) error {
+	current, err := currentBranch(repo)
+	if err == nil && current == branch {
+		return nil
+	}
+	branchRef := plumbing.NewBranchReferenceName(branch)
+	if _, err := repo.Reference(branchRef, false); err == nil {
+		return worktree.Checkout(&git.CheckoutOptions{Branch: branchRef})
+	}
+	remoteRef, err := repo.Reference(plumbing.NewRemoteReferenceName(m.Git.Remote, branch), true)
+	if err != nil {
+		return fmt.Errorf("branch %s does not exist locally or on %s: %w", branch, m.Git.Remote, err)
+	}
+	return worktree.Checkout(&git.CheckoutOptions{Branch: branchRef, Hash: remoteRef.Hash(), Create: true})
+}
+
+// Bring in changes from the remote using the configured strategy
+func (m Markdown) pull(worktree *git.Worktree, branch string, options PushPullOptions) error {
+	switch m.Git.Pull {
+	case "":
+		return nil
+	case "fast-forward":
+		auth, err := m.gitAuth(options)
+		if err != nil {
+			return err
+		}
+		err = worktree.Pull(&git.PullOptions{
+			RemoteName:    m.Git.Remote,
+			ReferenceName: plumbing.NewBranchReferenceName(branch),
+			Auth:          auth,
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return fmt.Errorf("failed to fast-forward %s: %w", branch, err)
+		}
+		return nil
+	default:
+		// go-git can only fast-forward, so rebasing and merging is left to the git CLI
+		// It's given the same SSH key or HTTPS token as go-git, and never prompts, since nobody may be there to answer
+		flag := "--rebase"
+		if m.Git.Pull == "merge" {
+			flag = "--no-rebase"
+		}
+		env, cleanup, err := m.gitCliEnv(options)
+		if err != nil {
+			return fmt.Errorf("failed to set up credentials for git pull: %w", err)
+		}
+		defer cleanup()
+		cmd := exec.Command("git", "pull", flag, m.Git.Remote, branch)
+		cmd.Dir = m.GitDir
+		cmd.Env = env
+		if author := m.gitAuthor(); author != nil {
+			// Rebasing and merging can create commits, so they should have the same identity
+			cmd.Env = append(cmd.Env, "GIT_AUTHOR_NAME="+author.Name, "GIT_AUTHOR_EMAIL="+author.Email, "GIT_COMMITTER_NAME="+author.Name, "GIT_COMMITTER_EMAIL="+author.Email)
+		}
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("git pull %s failed: %w: %s", flag, err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+}
+
+// Commit the post's file and optionally push the changes to the Git repository.
+// If the post has its own branch, that branch is pushed, a pull request is opened in review mode, and the base branch is checked out again.
+func (m Markdown) Commit(change GitChange, push bool, options PushPullOptions) (hash string, err error) {
+	message, err := executeTemplate(m.Git.CommitMessage, change)
+	if err != nil {
+		return "", fmt.Errorf("failed to render commit message: %w", err)
+	}
+	return m.commitChanges([]GitChange{change}, message, change.Base, push, options)
+}
+
+// Stage each change, commit them with the message, and optionally push
+func (m Markdown) commitChanges(changes []GitChange, message string, base string, push bool, options PushPullOptions) (hash string, err error) {
+	repo, worktree, err := m.openRepository()
+	if err != nil {
+		return "", err
+	}
+	branch, err := currentBranch(repo)
+	if err != nil {
+		return "", err
+	}
+	if branch != base {
+		// Go back to the base branch so the next post starts from it, even if committing, pushing, or opening the pull request fails
+		defer func() {
+			if checkoutErr := worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(base)}); checkoutErr != nil && err == nil {
+				err = checkoutErr
+			}
+		}()
+	}
+	gitDir := filepath.Clean(m.GitDir)
+	for _, change := range changes {
+		path := change.Path
+		if path == "" {
+			path = change.Slug + ".md"
+		}
+		filePath := filepath.Clean(filepath.Join(m.ContentDir, path))
+		// Get the relative path of filePath to gitDir
+		relativePath, err := filepath.Rel(gitDir, filePath)
+		if err != nil {
+			return "", err
+		}
+		// Stage the file, or its removal if it was deleted
+		if _, err := os.Stat(filePath); os.IsNotExist(err) {
+			_, err = worktree.Remove(relativePath)
+			if err != nil {
+				return "", err
+			}
+		} else {
+			_, err = worktree.Add(relativePath)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	commitOptions := &git.CommitOptions{Author: m.gitAuthor()}
+	if err := m.setCommitSigning(commitOptions, options); err != nil {
+		return "", err
+	}
+	commitHash, err := worktree.Commit(message, commitOptions)
+	if err != nil {
+		return "", err
+	}
+	if push {
+		// Post branches belong to cross-blogger, so they're force pushed in case an older version of the post was pushed
+		refSpec := config.RefSpec(fmt.Sprintf("refs/heads/%s:refs/heads/%s", branch, branch))
+		if branch != base {
+			refSpec = "+" + refSpec
+		}
+		auth, err := m.gitAuth(options)
+		if err != nil {
+			return "", err
+		}
+		err = repo.Push(&git.PushOptions{
+			RemoteName: m.Git.Remote,
+			RefSpecs:   []config.RefSpec{refSpec},
+			Auth:       auth,
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return "", err
+		}
+	}
+	if branch != base && push && m.Git.Review {
+		client, err := forge.New(m.Git.Forge, m.Git.ForgeUrl, options.ForgeToken)
+		if err != nil {
+			return "", err
+		}
+		url, err := client.CreatePullRequest(forge.PullRequest{
+			Repository: m.Git.Repository,
+			// Use the first line of the commit message as the title
+			Title: strings.SplitN(message, "\n", 2)[0],
+			Body:  "This pull request was opened by cross-blogger.\n\n" + message,
+			Head:  branch,
+			Base:  base,
+		})
+		// The branch was force pushed, so a pull request that's already open for it now has the new version of the post
+		if errors.Is(err, forge.ErrPullRequestExists) {
+			log.Info("Updated the open pull request for the post", "branch", branch)
+		} else if err != nil {
+			return "", err
+		} else {
+			log.Info("Opened pull request", "url", url)
+		}
+	}
+	return commitHash.String(), nil
+}
+
+// Check out the base branch after pushing a post failed on its own branch, so the next post starts from the base branch.
+// Failing to do so is only logged, since the error that caused it is more useful.
+func (m Markdown) leavePostBranch(base string) {
+	repo, worktree, err := m.openRepository()
+	if err == nil {
+		err = m.checkoutBranch(repo, worktree, base)
+	}
+	if err != nil {
+		log.Error("Failed to check out the base branch", "base", base, "error", err)
+	}
+}
@@ -0,0 +1,168 @@
+package platforms
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+// The default commit message. It has access to the fields of GitChange, such as .Slug and .Post.Title.
+const DefaultCommitMessage = `{{if .Deleted}}Delete{{else}}Update{{end}} {{.Slug}}.md`
+
+// Return the credentials used to pull and push, or nil to use go-git's defaults.
+// An SSH key takes precedence over an HTTPS token.
+func (m Markdown) gitAuth(options PushPullOptions) (transport.AuthMethod, error) {
+	if m.Git.SshKey != "" {
+		auth, err := ssh.NewPublicKeysFromFile("git", m.Git.SshKey, options.GitSshPassphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load SSH key %s: %w", m.Git.SshKey, err)
+		}
+		return auth, nil
+	}
+	if options.GitToken != "" {
+		return &http.BasicAuth{Username: m.Git.HttpsUsername, Password: options.GitToken}, nil
+	}
+	return nil, nil
+}
+
+// The script the git CLI runs, through GIT_ASKPASS and SSH_ASKPASS, to get credentials.
+// Git asks for the username and then the password, and SSH asks for the key's passphrase.
+// The credentials are passed in environment variables so they aren't written to disk or shown in the process list.
+const askpassScript = `#!/bin/sh
+case "$1" in
+Username*) printf '%s\n' "$CROSS_BLOGGER_GIT_USERNAME" ;;
+*) printf '%s\n' "$CROSS_BLOGGER_GIT_PASSWORD" ;;
+esac
+`
+
+// Return the environment for running the git CLI with the same credentials as gitAuth, without ever prompting on the terminal.
+// An SSH key takes precedence over an HTTPS token. The returned function removes the askpass script, if one was written, and should always be called.
+func (m Markdown) gitCliEnv(options PushPullOptions) ([]string, func(), error) {
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	username, secret := "", ""
+	if m.Git.SshKey != "" {
+		sshCommand := fmt.Sprintf("ssh -i %q -o IdentitiesOnly=yes", m.Git.SshKey)
+		if options.GitSshPassphrase != "" {
+			// The passphrase comes from the askpass script, which SSH won't ask in batch mode
+			secret = options.GitSshPassphrase
+		} else {
+			sshCommand += " -o BatchMode=yes"
+		}
+		env = append(env, "GIT_SSH_COMMAND="+sshCommand)
+	} else {
+		if os.Getenv("GIT_SSH_COMMAND") == "" {
+			env = append(env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+		}
+		if options.GitToken != "" {
+			username, secret = m.Git.HttpsUsername, options.GitToken
+		}
+	}
+	if secret == "" {
+		return env, func() {}, nil
+	}
+	script, err := os.CreateTemp("", "cross-blogger-askpass-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.Remove(script.Name()) }
+	if _, err := script.WriteString(askpassScript); err != nil {
+		script.Close()
+		cleanup()
+		return nil, nil, err
+	}
+	if err := script.Close(); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if err := os.Chmod(script.Name(), 0700); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	env = append(env,
+		"GIT_ASKPASS="+script.Name(),
+		// SSH only uses SSH_ASKPASS without a terminal when this is set (OpenSSH 8.4 and later)
+		"SSH_ASKPASS="+script.Name(),
+		"SSH_ASKPASS_REQUIRE=force",
+		"CROSS_BLOGGER_GIT_USERNAME="+username,
+		"CROSS_BLOGGER_GIT_PASSWORD="+secret,
+	)
+	return env, cleanup, nil
+}
+
+// Return the author of commits. If the name and email aren't configured, nil is returned and go-git falls back to the repository's Git config.
+func (m Markdown) gitAuthor() *object.Signature {
+	if m.Git.AuthorName == "" && m.Git.AuthorEmail == "" {
+		return nil
+	}
+	return &object.Signature{
+		Name:  m.Git.AuthorName,
+		Email: m.Git.AuthorEmail,
+		When:  time.Now(),
+	}
+}
+
+// Set up commit signing on the commit options, if enabled
+func (m Markdown) setCommitSigning(commitOptions *git.CommitOptions, options PushPullOptions) error {
+	switch m.Git.Sign {
+	case "":
+		return nil
+	case "gpg":
+		keyFile, err := os.Open(m.Git.SigningKey)
+		if err != nil {
+			return err
+		}
+		defer keyFile.Close()
+		keyRing, err := openpgp.ReadArmoredKeyRing(keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read GPG key %s: %w", m.Git.SigningKey, err)
+		}
+		if len(keyRing) == 0 {
+			return fmt.Errorf("no keys found in %s", m.Git.SigningKey)
+		}
+		key := keyRing[0]
+		if key.PrivateKey == nil {
+			return fmt.Errorf("%s does not contain a private key", m.Git.SigningKey)
+		}
+		if key.PrivateKey.Encrypted {
+			if err := key.DecryptPrivateKeys([]byte(options.GitSigningPassphrase)); err != nil {
+				return fmt.Errorf("failed to decrypt GPG key %s: %w", m.Git.SigningKey, err)
+			}
+		}
+		commitOptions.SignKey = key
+		return nil
+	case "ssh":
+		commitOptions.Signer = sshSigner{KeyPath: m.Git.SigningKey}
+		return nil
+	default:
+		return fmt.Errorf("unknown signing method: %s", m.Git.Sign)
+	}
+}
+
+// sshSigner signs commits with an SSH key using ssh-keygen, the same way Git does when gpg.format is ssh.
+// If the key has a passphrase, it should be loaded into ssh-agent.
+type sshSigner struct {
+	KeyPath string
+}
+
+func (s sshSigner) Sign(message io.Reader) ([]byte, error) {
+	cmd := exec.Command("ssh-keygen", "-Y", "sign", "-n", "git", "-f", s.KeyPath)
+	cmd.Stdin = message
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	signature, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ssh-keygen failed to sign commit: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return signature, nil
+}
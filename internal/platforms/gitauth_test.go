@@ -0,0 +1,115 @@
+package platforms
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitCliEnv(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	tests := []struct {
+		name    string
+		git     GitOptions
+		options PushPullOptions
+		// want is what `git credential fill` should print, or empty if it should fail rather than prompt
+		want string
+	}{
+		{
+			name:    "https token",
+			git:     GitOptions{HttpsUsername: "git"},
+			options: PushPullOptions{GitToken: "token"},
+			want:    "protocol=https\nhost=example.com\nusername=git\npassword=token\n",
+		},
+		{
+			name:    "https token with a username",
+			git:     GitOptions{HttpsUsername: "octocat"},
+			options: PushPullOptions{GitToken: "token"},
+			want:    "protocol=https\nhost=example.com\nusername=octocat\npassword=token\n",
+		},
+		{
+			name: "no credentials",
+			git:  GitOptions{HttpsUsername: "git"},
+		},
+		{
+			// The SSH key takes precedence, so the token isn't given to HTTPS remotes
+			name:    "ssh key without a passphrase",
+			git:     GitOptions{HttpsUsername: "git", SshKey: "/nonexistent"},
+			options: PushPullOptions{GitToken: "token"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Markdown{Git: tt.git}
+			env, cleanup, err := m.gitCliEnv(tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cleanup()
+			// Don't let the credential helpers of whoever runs the tests answer
+			cmd := exec.Command("git", "-c", "credential.helper=", "credential", "fill")
+			cmd.Env = append(env, "GIT_CONFIG_NOSYSTEM=1", "HOME="+t.TempDir())
+			cmd.Stdin = strings.NewReader("protocol=https\nhost=example.com\n\n")
+			output, err := cmd.Output()
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("git credential fill = %q, want it to fail without prompting", output)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("git credential fill failed: %v", err)
+			}
+			if string(output) != tt.want {
+				t.Errorf("git credential fill = %q, want %q", output, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitCliEnvSshPassphrase(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen isn't installed")
+	}
+	key := filepath.Join(t.TempDir(), "key")
+	if output, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "passphrase", "-f", key).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen failed: %v: %s", err, output)
+	}
+	m := Markdown{Git: GitOptions{SshKey: key}}
+	env, cleanup, err := m.gitCliEnv(PushPullOptions{GitSshPassphrase: "passphrase"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	if !containsPrefix(env, "SSH_ASKPASS=") || containsPrefix(env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes") {
+		t.Fatalf("environment doesn't use the askpass script for the key: %q", env)
+	}
+	// ssh-keygen reads passphrases the same way as ssh, so it can check the key is unlocked without a server
+	cmd := exec.Command("ssh-keygen", "-y", "-f", key)
+	cmd.Env = env
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("the key wasn't unlocked: %v: %s", err, output)
+	}
+
+	cleanup()
+	if _, err := os.Stat(strings.TrimPrefix(findPrefix(env, "SSH_ASKPASS="), "SSH_ASKPASS=")); !os.IsNotExist(err) {
+		t.Errorf("the askpass script wasn't removed: %v", err)
+	}
+}
+
+func containsPrefix(items []string, prefix string) bool {
+	return findPrefix(items, prefix) != ""
+}
+
+func findPrefix(items []string, prefix string) string {
+	for _, item := range items {
+		if strings.HasPrefix(item, prefix) {
+			return item
+		}
+	}
+	return ""
+}
@@ -0,0 +1,110 @@
+package platforms
+
+import (
+	"fmt"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// The default commit message for batches. It has access to the fields of GitBatchSummary.
+const DefaultBatchCommitMessage = `{{if .Updated}}Update {{.Updated}} post{{if ne .Updated 1}}s{{end}}{{end}}{{if and .Updated .Deleted}} and delete{{else if .Deleted}}Delete{{end}}{{if .Deleted}} {{.Deleted}} post{{if ne .Deleted 1}}s{{end}}{{end}}
+
+{{range .Changes}}- {{if .Deleted}}Delete{{else}}Update{{end}} {{.Slug}}.md
+{{end}}`
+
+// GitBatch collects changes to many posts so they're committed and pushed once, rather than once per post.
+// Create one with Markdown.BeginBatch and pass it to Push through PushPullOptions.GitBatch.
+// It's safe to add changes from multiple goroutines.
+type GitBatch struct {
+	mu      sync.Mutex
+	base    string
+	changes []GitChange
+}
+
+// GitBatchSummary is passed to the batch commit message template
+type GitBatchSummary struct {
+	Changes []GitChange
+	Updated int
+	Deleted int
+}
+
+// Add a change to the batch. If the post was already changed in this batch, the earlier change is replaced.
+func (b *GitBatch) Add(change GitChange) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	change.Base = b.base
+	for i, existing := range b.changes {
+		if existing.Slug == change.Slug {
+			b.changes[i] = change
+			return
+		}
+	}
+	b.changes = append(b.changes, change)
+}
+
+// Return the number of changes in the batch
+func (b *GitBatch) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.changes)
+}
+
+// BeginBatch gets the repository ready for a batch of changes, the same way PrepareGit does for a single post.
+// If post branches are enabled, the batch gets a single branch named after the time it started.
+func (m Markdown) BeginBatch(options PushPullOptions) (*GitBatch, error) {
+	base, err := m.PrepareGit("batch-"+time.Now().UTC().Format("20060102-150405"), options)
+	if err != nil {
+		return nil, err
+	}
+	return &GitBatch{base: base}, nil
+}
+
+// CommitBatch commits every change in the batch with a summarised message and optionally pushes them.
+// If the batch is empty, nothing is committed and an empty hash is returned.
+func (m Markdown) CommitBatch(batch *GitBatch, push bool, options PushPullOptions) (hash string, err error) {
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	if len(batch.changes) == 0 {
+		log.Debug("No changes in batch; not committing", "destination", m.Name)
+		// The batch's branch (if any) is left behind, so go back to the base branch
+		if m.Git.PostBranches {
+			repo, worktree, err := m.openRepository()
+			if err != nil {
+				return "", err
+			}
+			if err := m.checkoutBranch(repo, worktree, batch.base); err != nil {
+				return "", err
+			}
+		}
+		return "", nil
+	}
+	summary := GitBatchSummary{Changes: batch.changes}
+	for _, change := range batch.changes {
+		if change.Deleted {
+			summary.Deleted++
+		} else {
+			summary.Updated++
+		}
+	}
+	message, err := executeTemplate(m.Git.BatchCommitMessage, summary)
+	if err != nil {
+		return "", fmt.Errorf("failed to render batch commit message: %w", err)
+	}
+	hash, err = m.commitChanges(batch.changes, message, batch.base, push, options)
+	if err != nil {
+		return "", err
+	}
+	batch.changes = nil
+	return hash, nil
+}
+
+// Parse the batch commit message template, falling back to the default
+func parseBatchCommitMessage(message string) (*template.Template, error) {
+	if message == "" {
+		message = DefaultBatchCommitMessage
+	}
+	return template.New("batch_commit_message").Parse(message)
+}
@@ -0,0 +1,76 @@
+package platforms
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+	goldmarkfrontmatter "go.abhg.dev/goldmark/frontmatter"
+)
+
+// DefaultGoldmarkExtensions are enabled when goldmark_extensions isn't set
+var DefaultGoldmarkExtensions = []string{"gfm", "footnote", "definition_list", "typographer", "heading_ids"}
+
+// The extensions that can be enabled by name.
+// heading_ids isn't an extension but a parser option, so it's handled separately.
+var goldmarkExtensions = map[string]goldmark.Extender{
+	"gfm":             extension.GFM,
+	"table":           extension.Table,
+	"strikethrough":   extension.Strikethrough,
+	"linkify":         extension.Linkify,
+	"task_list":       extension.TaskList,
+	"footnote":        extension.Footnote,
+	"definition_list": extension.DefinitionList,
+	"typographer":     extension.Typographer,
+	"cjk":             extension.CJK,
+}
+
+// Check that each extension is known and that the syntax highlighting style exists
+func validateGoldmarkOptions(extensions []string, highlightStyle string) error {
+	for _, name := range extensions {
+		if _, ok := goldmarkExtensions[name]; !ok && name != "heading_ids" {
+			return fmt.Errorf("unknown goldmark extension: %s", name)
+		}
+	}
+	if highlightStyle != "" {
+		if _, ok := styles.Registry[highlightStyle]; !ok {
+			return fmt.Errorf("unknown syntax highlighting style: %s", highlightStyle)
+		}
+	}
+	return nil
+}
+
+// Create a Goldmark instance with the frontmatter extension, the named extensions, and optionally syntax highlighting.
+// If highlightStyle is set, code blocks are highlighted with Chroma using inline styles so they render on platforms that strip stylesheets, such as Blogger.
+func newGoldmark(extensions []string, highlightStyle string) goldmark.Markdown {
+	extenders := []goldmark.Extender{&goldmarkfrontmatter.Extender{
+		Mode: goldmarkfrontmatter.SetMetadata,
+	}}
+	parserOptions := []parser.Option{}
+	for _, name := range extensions {
+		if name == "heading_ids" {
+			parserOptions = append(parserOptions, parser.WithAutoHeadingID())
+			continue
+		}
+		if extender, ok := goldmarkExtensions[name]; ok {
+			extenders = append(extenders, extender)
+		}
+	}
+	if highlightStyle != "" {
+		extenders = append(extenders, highlighting.NewHighlighting(
+			highlighting.WithStyle(highlightStyle),
+			highlighting.WithFormatOptions(html.WithClasses(false)),
+		))
+	}
+	return goldmark.New(
+		goldmark.WithExtensions(extenders...),
+		goldmark.WithParserOptions(parserOptions...),
+		// The Markdown is written by the author, so raw HTML (such as embeds) should be kept
+		goldmark.WithRendererOptions(goldmarkhtml.WithUnsafe()),
+	)
+}
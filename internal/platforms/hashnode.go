@@ -0,0 +1,170 @@
+package platforms
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gosimple/slug"
+	"github.com/slashtechno/cross-blogger/pkg/graphql"
+)
+
+// Hashnode allows up to five tags on a post
+const hashnodeMaxTags = 5
+
+// Hashnode publishes posts to a Hashnode publication through its GraphQL API
+type Hashnode struct {
+	Name string
+	// ApiUrl is the URL of the GraphQL API. It defaults to https://gql.hashnode.com.
+	ApiUrl string
+	// Either the ID of the publication or its host (such as blog.example.com) is needed. If only the host is set, the ID is looked up.
+	PublicationId   string
+	PublicationHost string
+	// CoverImage uses the first image in the post as its cover image
+	CoverImage bool
+	// PostIds remembers the ID of each published post so pushing it again updates it
+	PostIds PostIds
+	Transforms
+	PushHooks
+}
+
+func (h Hashnode) GetName() string { return h.Name }
+func (h Hashnode) GetType() string { return "hashnode" }
+
+const hashnodePublicationQuery = `query Publication($host: String!) {
+	publication(host: $host) {
+		id
+	}
+}`
+
+const hashnodePublishMutation = `mutation PublishPost($input: PublishPostInput!) {
+	publishPost(input: $input) {
+		post {
+			id
+			url
+		}
+	}
+}`
+
+const hashnodeUpdateMutation = `mutation UpdatePost($input: UpdatePostInput!) {
+	updatePost(input: $input) {
+		post {
+			id
+			url
+		}
+	}
+}`
+
+type hashnodePost struct {
+	Id  string `json:"id"`
+	Url string `json:"url"`
+}
+
+// Push publishes the post or, if it was published to this destination before, updates it.
+// Hashnode drafts can't be created through publishPost, so drafts are skipped.
+func (h Hashnode) Push(data PostData, options PushPullOptions) error {
+	if data.Draft {
+		log.Info("Skipping draft since Hashnode posts are published immediately", "title", data.Title, "destination", h.Name)
+		return nil
+	}
+	if options.HashnodeToken == "" {
+		return errors.New("a Hashnode token is required")
+	}
+	client := graphql.Client{
+		Url:     h.ApiUrl,
+		Headers: map[string]string{"Authorization": options.HashnodeToken},
+	}
+
+	input := map[string]interface{}{
+		"title":           data.Title,
+		"contentMarkdown": data.Markdown,
+		"slug":            slug.Make(data.Title),
+		"tags":            hashnodeTags(data.Tags),
+	}
+	if data.Description != "" {
+		input["subtitle"] = data.Description
+	}
+	if data.CanonicalUrl != "" {
+		input["originalArticleURL"] = data.CanonicalUrl
+	}
+	if !data.Date.IsZero() {
+		input["publishedAt"] = data.Date.Format(time.RFC3339)
+	}
+	if h.CoverImage {
+		if cover := firstImage(data.Html); cover != "" {
+			input["coverImageOptions"] = map[string]interface{}{"coverImageURL": cover}
+		}
+	}
+
+	defer h.PostIds.Lock(h.Name, data)()
+	postId, err := h.PostIds.Get(h.Name, data)
+	if err != nil {
+		return err
+	}
+	if postId != "" {
+		input["id"] = postId
+		var result struct {
+			UpdatePost struct {
+				Post hashnodePost `json:"post"`
+			} `json:"updatePost"`
+		}
+		if err := client.Do(hashnodeUpdateMutation, map[string]interface{}{"input": input}, &result); err != nil {
+			return fmt.Errorf("failed to update Hashnode post %s: %w", postId, err)
+		}
+		log.Info("Updated Hashnode post", "destination", h.Name, "url", result.UpdatePost.Post.Url)
+		return nil
+	}
+
+	publicationId, err := h.publicationId(client)
+	if err != nil {
+		return err
+	}
+	input["publicationId"] = publicationId
+	var result struct {
+		PublishPost struct {
+			Post hashnodePost `json:"post"`
+		} `json:"publishPost"`
+	}
+	if err := client.Do(hashnodePublishMutation, map[string]interface{}{"input": input}, &result); err != nil {
+		return fmt.Errorf("failed to publish Hashnode post: %w", err)
+	}
+	log.Info("Published Hashnode post", "destination", h.Name, "url", result.PublishPost.Post.Url)
+	if err := h.PostIds.Set(h.Name, data, result.PublishPost.Post.Id); err != nil {
+		// Without the ID, pushing the post again would publish a duplicate, so say what it was
+		return fmt.Errorf("published Hashnode post %s (ID %s) but failed to record its ID in %s, so add it there to avoid a duplicate: %w", result.PublishPost.Post.Url, result.PublishPost.Post.Id, h.PostIds.File, err)
+	}
+	return nil
+}
+
+// Return the ID of the publication, looking it up by host if it isn't set
+func (h Hashnode) publicationId(client graphql.Client) (string, error) {
+	if h.PublicationId != "" {
+		return h.PublicationId, nil
+	}
+	var result struct {
+		Publication *struct {
+			Id string `json:"id"`
+		} `json:"publication"`
+	}
+	if err := client.Do(hashnodePublicationQuery, map[string]interface{}{"host": h.PublicationHost}, &result); err != nil {
+		return "", fmt.Errorf("failed to look up Hashnode publication: %w", err)
+	}
+	if result.Publication == nil {
+		return "", fmt.Errorf("Hashnode publication not found: %s", h.PublicationHost)
+	}
+	return result.Publication.Id, nil
+}
+
+// Convert tags to Hashnode's tag input, keeping the first five
+func hashnodeTags(tags []string) []map[string]string {
+	hashnodeTags := []map[string]string{}
+	for _, tag := range tags {
+		if len(hashnodeTags) == hashnodeMaxTags {
+			log.Warn("Hashnode only allows five tags. Leaving out the rest", "tags", tags)
+			break
+		}
+		hashnodeTags = append(hashnodeTags, map[string]string{"slug": slug.Make(tag), "name": tag})
+	}
+	return hashnodeTags
+}
@@ -0,0 +1,208 @@
+package platforms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gosimple/slug"
+)
+
+// The default time a hook command can run for before it's killed
+const DefaultHookTimeout = 5 * time.Minute
+
+// ErrPrePushAborted is wrapped by the error returned when a pre_push or pre_commit hook exits with a non-zero status
+var ErrPrePushAborted = errors.New("hook aborted the push")
+
+// HookCommand is a command run before or after a post is pushed.
+// The post is written to its stdin as JSON, and details about the post and destination are in environment variables starting with CROSS_BLOGGER_.
+type HookCommand struct {
+	// If Shell is true, Args has a single element that's run with sh -c. Otherwise, Args[0] is executed with the rest as its arguments.
+	Args    []string
+	Shell   bool
+	Timeout time.Duration
+}
+
+// PushHooks is embedded in destinations to run commands before and after each push.
+// It is configured with the pre_push, post_push, pre_commit, and hook_timeout keys of a destination.
+type PushHooks struct {
+	// PrePush can stop the push by exiting with a non-zero status, or replace the post by printing it as JSON
+	PrePush  *HookCommand
+	PostPush *HookCommand
+	// PreCommit is only used by Markdown destinations. It runs after the post's file is written but before it's committed, so it can check the site with the new post, such as by building it.
+	PreCommit *HookCommand
+}
+
+// HookableDestination is a destination that can have commands run before and after each push
+type HookableDestination interface {
+	Destination
+	RunPrePush(Destination, PostData) (PostData, error)
+	RunPostPush(Destination, PostData) error
+}
+
+// RunPrePush runs the pre_push hook, if there is one, and returns the post to push.
+// If the hook prints a JSON object, it replaces the post. Anything else it prints is logged.
+func (h PushHooks) RunPrePush(destination Destination, data PostData) (PostData, error) {
+	if h.PrePush == nil {
+		return data, nil
+	}
+	stdout, err := h.PrePush.run("pre_push", destination, data, "")
+	if err != nil {
+		return PostData{}, fmt.Errorf("%w: %w", ErrPrePushAborted, err)
+	}
+	output := strings.TrimSpace(stdout)
+	if !strings.HasPrefix(output, "{") {
+		if output != "" {
+			log.Info("pre_push hook output", "destination", destination.GetName(), "output", output)
+		}
+		return data, nil
+	}
+	var modified PostData
+	if err := json.Unmarshal([]byte(output), &modified); err != nil {
+		return PostData{}, fmt.Errorf("%w: the post the hook printed isn't valid: %w", ErrPrePushAborted, err)
+	}
+	// Hooks don't need to keep the source, since it isn't part of the post itself
+	if modified.Source == "" {
+		modified.Source = data.Source
+	}
+	log.Debug("pre_push hook replaced the post", "destination", destination.GetName(), "title", modified.Title)
+	return modified, nil
+}
+
+// RunPostPush runs the post_push hook, if there is one
+func (h PushHooks) RunPostPush(destination Destination, data PostData) error {
+	if h.PostPush == nil {
+		return nil
+	}
+	stdout, err := h.PostPush.run("post_push", destination, data, "")
+	if output := strings.TrimSpace(stdout); output != "" {
+		log.Info("post_push hook output", "destination", destination.GetName(), "output", output)
+	}
+	return err
+}
+
+// RunPreCommit runs the pre_commit hook, if there is one, in dir with the path of the post's file in CROSS_BLOGGER_FILE.
+// If the hook fails, the returned error wraps ErrPrePushAborted.
+func (h PushHooks) RunPreCommit(destination Destination, data PostData, dir string, file string) error {
+	if h.PreCommit == nil {
+		return nil
+	}
+	stdout, err := h.PreCommit.run("pre_commit", destination, data, dir, "CROSS_BLOGGER_FILE="+file)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrPrePushAborted, err)
+	}
+	if output := strings.TrimSpace(stdout); output != "" {
+		log.Info("pre_commit hook output", "destination", destination.GetName(), "output", output)
+	}
+	return nil
+}
+
+// Run the command with the post on stdin and return what it printed to stdout.
+// If dir isn't empty, the command is run in it. extraEnv is added to the environment.
+// If it fails, the error includes what it printed to stderr.
+func (c HookCommand) run(hook string, destination Destination, data PostData, dir string, extraEnv ...string) (string, error) {
+	input, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if c.Shell {
+		cmd = exec.CommandContext(ctx, "sh", "-c", c.Args[0])
+	} else {
+		cmd = exec.CommandContext(ctx, c.Args[0], c.Args[1:]...)
+	}
+	// Don't wait for children of the shell that still have stdout open after it's killed
+	cmd.WaitDelay = time.Second
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(),
+		"CROSS_BLOGGER_HOOK="+hook,
+		"CROSS_BLOGGER_DESTINATION="+destination.GetName(),
+		"CROSS_BLOGGER_DESTINATION_TYPE="+destination.GetType(),
+		"CROSS_BLOGGER_SOURCE="+data.Source,
+		"CROSS_BLOGGER_POST_ID="+PostKey(data),
+		"CROSS_BLOGGER_SLUG="+slug.Make(data.Title),
+		"CROSS_BLOGGER_TITLE="+data.Title,
+		"CROSS_BLOGGER_CANONICAL_URL="+data.CanonicalUrl,
+		"CROSS_BLOGGER_DRAFT="+strconv.FormatBool(data.Draft),
+	)
+	cmd.Env = append(cmd.Env, extraEnv...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	start := time.Now()
+	err = cmd.Run()
+	log.Debug("Ran hook", "hook", hook, "destination", destination.GetName(), "duration", time.Since(start), "stderr", stderr.String())
+	if ctx.Err() == context.DeadlineExceeded {
+		return stdout.String(), fmt.Errorf("%s hook timed out after %s", hook, c.Timeout)
+	}
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return stdout.String(), fmt.Errorf("%s hook failed: %w: %s", hook, err, message)
+		}
+		return stdout.String(), fmt.Errorf("%s hook failed: %w", hook, err)
+	}
+	return stdout.String(), nil
+}
+
+// Convert the pre_push, post_push, pre_commit, and hook_timeout keys of a destination to a PushHooks struct
+func PushHooksFromInterface(destMap map[string]interface{}) (PushHooks, error) {
+	timeout := DefaultHookTimeout
+	if t, ok := destMap["hook_timeout"].(string); ok && t != "" {
+		var err error
+		timeout, err = time.ParseDuration(t)
+		if err != nil {
+			return PushHooks{}, fmt.Errorf("hook_timeout: %w", err)
+		}
+	}
+	hooks := PushHooks{}
+	var err error
+	hooks.PrePush, err = hookCommandFromInterface(destMap["pre_push"], timeout)
+	if err != nil {
+		return PushHooks{}, fmt.Errorf("pre_push: %w", err)
+	}
+	hooks.PostPush, err = hookCommandFromInterface(destMap["post_push"], timeout)
+	if err != nil {
+		return PushHooks{}, fmt.Errorf("post_push: %w", err)
+	}
+	hooks.PreCommit, err = hookCommandFromInterface(destMap["pre_commit"], timeout)
+	if err != nil {
+		return PushHooks{}, fmt.Errorf("pre_commit: %w", err)
+	}
+	if hooks.PreCommit != nil && destMap["type"] != "markdown" {
+		return PushHooks{}, errors.New("pre_commit is only supported by markdown destinations")
+	}
+	return hooks, nil
+}
+
+// A hook is either a string run with the shell or a list of an executable and its arguments
+func hookCommandFromInterface(h interface{}, timeout time.Duration) (*HookCommand, error) {
+	switch hook := h.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if hook == "" {
+			return nil, nil
+		}
+		return &HookCommand{Args: []string{hook}, Shell: true, Timeout: timeout}, nil
+	default:
+		args, err := stringSliceFromInterface(hook)
+		if err != nil {
+			return nil, errors.New("should be a string or a list of strings")
+		}
+		if len(args) == 0 {
+			return nil, nil
+		}
+		return &HookCommand{Args: args, Timeout: timeout}, nil
+	}
+}
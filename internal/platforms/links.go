@@ -0,0 +1,115 @@
+package platforms
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// LinkIndex maps posts on a source blog to the slugs that destinations use for them.
+// It is used to rewrite links between posts on the same blog so they point at the destination's copy.
+type LinkIndex struct {
+	// BlogUrl is the URL of the source blog. Only links pointing at this host are considered internal.
+	BlogUrl string
+	// Slugs maps the path of a post on the source blog (such as /2024/06/foo.html) to its slug
+	Slugs map[string]string
+}
+
+// IndexableSource is a source that can list its posts so that links between them can be rewritten
+type IndexableSource interface {
+	Source
+	BuildLinkIndex(PushPullOptions) (LinkIndex, error)
+}
+
+// LinkRewriter is a destination that can point internal links at its own copies of posts.
+// RewriteLinks returns the modified post data along with any internal links that could not be resolved.
+// RewritesLinks reports whether rewriting is enabled so that an index is only built when needed.
+type LinkRewriter interface {
+	RewriteLinks(PostData, LinkIndex) (PostData, []string)
+	RewritesLinks() bool
+}
+
+// Match the target of inline Markdown links, such as [text](https://example.com/post.html "title")
+var markdownLinkRegex = regexp.MustCompile(`\]\(\s*(<[^>]*>|[^)\s]+)`)
+
+// Match href attributes in HTML, such as <a href="https://example.com/post.html">
+var hrefRegex = regexp.MustCompile(`href\s*=\s*("[^"]*"|'[^']*')`)
+
+// Resolve returns the slug of the post that link points to.
+// internal is true if the link points at the source blog, regardless of whether the post was found.
+func (l LinkIndex) Resolve(link string) (slug string, fragment string, internal bool) {
+	blogUrl, err := url.Parse(l.BlogUrl)
+	if err != nil || blogUrl.Host == "" {
+		return "", "", false
+	}
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Host == "" {
+		return "", "", false
+	}
+	if normalizeHost(parsed.Host) != normalizeHost(blogUrl.Host) {
+		return "", "", false
+	}
+	// Links to the blog itself (or to label and search pages) aren't posts, so leave them be
+	path := parsed.Path
+	if path == "" || path == "/" || strings.HasPrefix(path, "/search") || strings.HasPrefix(path, "/p/") {
+		return "", "", false
+	}
+	slug, ok := l.Slugs[path]
+	if !ok {
+		return "", "", true
+	}
+	return slug, parsed.Fragment, true
+}
+
+// RewriteInternalLinks replaces links in content that point at posts in the index.
+// target is called with the slug and fragment (if any) of the linked post and should return the new link.
+// Any internal links that could not be resolved are returned so they can be reported.
+func RewriteInternalLinks(content string, index LinkIndex, target func(slug string, fragment string) string) (string, []string) {
+	unresolved := []string{}
+	rewrite := func(link string) (string, bool) {
+		slug, fragment, internal := index.Resolve(link)
+		if !internal {
+			return link, false
+		}
+		if slug == "" {
+			unresolved = append(unresolved, link)
+			return link, false
+		}
+		return target(slug, fragment), true
+	}
+
+	content = markdownLinkRegex.ReplaceAllStringFunc(content, func(match string) string {
+		link := strings.TrimSpace(strings.TrimPrefix(match, "]("))
+		link = strings.TrimSuffix(strings.TrimPrefix(link, "<"), ">")
+		newLink, ok := rewrite(link)
+		if !ok {
+			return match
+		}
+		return "](" + newLink
+	})
+	content = hrefRegex.ReplaceAllStringFunc(content, func(match string) string {
+		quoted := hrefRegex.FindStringSubmatch(match)[1]
+		quote := quoted[:1]
+		newLink, ok := rewrite(quoted[1 : len(quoted)-1])
+		if !ok {
+			return match
+		}
+		return "href=" + quote + newLink + quote
+	})
+	return content, unresolved
+}
+
+// Treat www.example.com and example.com as the same host
+func normalizeHost(host string) string {
+	return strings.TrimPrefix(strings.ToLower(host), "www.")
+}
+
+// Return the path of a post URL so that it can be used as a key in LinkIndex.Slugs
+func linkIndexKey(postUrl string) (string, error) {
+	parsed, err := url.Parse(postUrl)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse post url %s: %w", postUrl, err)
+	}
+	return parsed.Path, nil
+}
@@ -0,0 +1,126 @@
+package platforms
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testLinkIndex = LinkIndex{
+	BlogUrl: "https://blog.example.com",
+	Slugs: map[string]string{
+		"/2024/06/foo.html": "foo",
+		"/2024/07/bar.html": "bar",
+	},
+}
+
+func TestRewriteInternalLinks(t *testing.T) {
+	target := func(slug string, fragment string) string {
+		if fragment != "" {
+			return "/posts/" + slug + "/#" + fragment
+		}
+		return "/posts/" + slug + "/"
+	}
+	tests := []struct {
+		name           string
+		content        string
+		want           string
+		wantUnresolved []string
+	}{
+		{
+			name:    "markdown link",
+			content: "See [foo](https://blog.example.com/2024/06/foo.html).",
+			want:    "See [foo](/posts/foo/).",
+		},
+		{
+			name:    "markdown link with angle brackets and a title",
+			content: `[foo](<https://blog.example.com/2024/06/foo.html> "Foo")`,
+			want:    `[foo](/posts/foo/ "Foo")`,
+		},
+		{
+			name:    "fragment",
+			content: "[setup](https://blog.example.com/2024/06/foo.html#setup)",
+			want:    "[setup](/posts/foo/#setup)",
+		},
+		{
+			name:    "www and case don't matter for the host",
+			content: "[foo](https://www.Blog.Example.com/2024/06/foo.html)",
+			want:    "[foo](/posts/foo/)",
+		},
+		{
+			name:    "html links with either quote",
+			content: `<a href="https://blog.example.com/2024/06/foo.html">foo</a> <a href='https://blog.example.com/2024/07/bar.html#end'>bar</a>`,
+			want:    `<a href="/posts/foo/">foo</a> <a href='/posts/bar/#end'>bar</a>`,
+		},
+		{
+			name:           "unresolved links are left alone and reported",
+			content:        "[gone](https://blog.example.com/2024/06/missing.html) [foo](https://blog.example.com/2024/06/foo.html) <a href=\"https://blog.example.com/2020/01/old.html\">old</a>",
+			want:           "[gone](https://blog.example.com/2024/06/missing.html) [foo](/posts/foo/) <a href=\"https://blog.example.com/2020/01/old.html\">old</a>",
+			wantUnresolved: []string{"https://blog.example.com/2024/06/missing.html", "https://blog.example.com/2020/01/old.html"},
+		},
+		{
+			name:    "other hosts",
+			content: "[foo](https://example.org/2024/06/foo.html) <a href=\"https://blog.example.net/2024/06/foo.html\">foo</a>",
+			want:    "[foo](https://example.org/2024/06/foo.html) <a href=\"https://blog.example.net/2024/06/foo.html\">foo</a>",
+		},
+		{
+			name:    "pages that aren't posts",
+			content: "[home](https://blog.example.com/) [go](https://blog.example.com/search/label/go) [about](https://blog.example.com/p/about.html)",
+			want:    "[home](https://blog.example.com/) [go](https://blog.example.com/search/label/go) [about](https://blog.example.com/p/about.html)",
+		},
+		{
+			name:    "relative links",
+			content: "[foo](/2024/06/foo.html) [top](#top)",
+			want:    "[foo](/2024/06/foo.html) [top](#top)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unresolved := RewriteInternalLinks(tt.content, testLinkIndex, target)
+			if got != tt.want {
+				t.Errorf("content = %q, want %q", got, tt.want)
+			}
+			if tt.wantUnresolved == nil {
+				tt.wantUnresolved = []string{}
+			}
+			if !reflect.DeepEqual(unresolved, tt.wantUnresolved) {
+				t.Errorf("unresolved = %q, want %q", unresolved, tt.wantUnresolved)
+			}
+		})
+	}
+}
+
+func TestMarkdownRewriteLinks(t *testing.T) {
+	content := "[foo](https://blog.example.com/2024/06/foo.html) [setup](https://blog.example.com/2024/06/foo.html#setup)"
+	tests := []struct {
+		name     string
+		markdown Markdown
+		want     string
+	}{
+		{
+			name:     "hugo ref",
+			markdown: Markdown{InternalLinks: "ref"},
+			want:     `[foo]({{< ref "foo.md" >}}) [setup]({{< ref "foo.md#setup" >}})`,
+		},
+		{
+			name:     "url",
+			markdown: Markdown{InternalLinks: "url", BaseUrl: "https://example.com/posts/"},
+			want:     "[foo](https://example.com/posts/foo/) [setup](https://example.com/posts/foo/#setup)",
+		},
+		{
+			name:     "disabled",
+			markdown: Markdown{},
+			want:     content,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unresolved := tt.markdown.RewriteLinks(PostData{Markdown: content}, testLinkIndex)
+			if got.Markdown != tt.want {
+				t.Errorf("Markdown = %q, want %q", got.Markdown, tt.want)
+			}
+			if len(unresolved) != 0 {
+				t.Errorf("unresolved = %q, want none", unresolved)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package platforms
+
+import (
+	"strings"
+	"time"
+)
+
+// ListedPost is a post found while listing a source. It has enough information to filter on without pulling the whole post.
+type ListedPost struct {
+	// Id identifies the post on the source, such as a Blogger post ID or a path relative to the content directory
+	Id    string
+	Title string
+	Date  time.Time
+	// Labels are the post's labels as they appear on the source.
+	// For Blogger, category labels keep their prefix. For Markdown, these are the categories and tags.
+	Labels []string
+	// Status is "live", "draft", or "scheduled"
+	Status string
+	// The post as the source returned it when listing, so it doesn't need to be fetched again
+	raw interface{}
+}
+
+// PostFilter narrows down the posts listed from a source. Zero values don't filter anything.
+type PostFilter struct {
+	// Only include posts published at or after Since and before Until
+	Since time.Time
+	Until time.Time
+	// Only include posts with at least one of these labels (case-insensitive)
+	Labels []string
+	// Only include posts with one of these statuses. If empty, only live posts are included.
+	Statuses []string
+}
+
+// ListableSource is a source that can enumerate all of its posts, such as for importing a whole blog
+type ListableSource interface {
+	Source
+	ListPosts(PushPullOptions, PostFilter) ([]ListedPost, error)
+	// PullListed pulls a post returned by ListPosts
+	PullListed(ListedPost, PushPullOptions) (PostData, error)
+}
+
+// Return the statuses the filter allows, defaulting to live posts only
+func (f PostFilter) statuses() []string {
+	if len(f.Statuses) == 0 {
+		return []string{"live"}
+	}
+	return f.Statuses
+}
+
+// Matches reports whether a listed post passes the filter
+func (f PostFilter) Matches(post ListedPost) bool {
+	if !f.Since.IsZero() && post.Date.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !post.Date.Before(f.Until) {
+		return false
+	}
+	if !containsFold(f.statuses(), post.Status) {
+		return false
+	}
+	if len(f.Labels) > 0 {
+		for _, label := range post.Labels {
+			if containsFold(f.Labels, label) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Check if a slice contains a string, ignoring case
+func containsFold(slice []string, item string) bool {
+	for _, s := range slice {
+		if strings.EqualFold(s, item) {
+			return true
+		}
+	}
+	return false
+}
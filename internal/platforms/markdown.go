@@ -2,21 +2,19 @@ package platforms
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
-	md "github.com/JohannesKaufmann/html-to-markdown"
 	"github.com/charmbracelet/log"
-	"github.com/go-git/go-git/v5"
 	"github.com/goccy/go-yaml"
 	"github.com/gosimple/slug"
-	"github.com/slashtechno/cross-blogger/pkg/utils"
 	"github.com/spf13/afero"
-	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/text"
-	goldmarkfrontmatter "go.abhg.dev/goldmark/frontmatter"
 )
 
 type Markdown struct {
@@ -24,23 +22,80 @@ type Markdown struct {
 	// ContentDir, for retrieving, should only be used if treating the passed post path as relative results in no file found
 	ContentDir string
 	GitDir     string
+	Git        GitOptions
 	// Example: []string{"title", "date", "lastmod", "canonicalURL"}
 	FrontmatterMapping
 	Overwrite bool
+	// InternalLinks controls how links to other posts on the source blog are rewritten.
+	// "ref" uses Hugo's ref shortcode, "url" uses BaseUrl, and "" leaves links untouched.
+	InternalLinks string
+	// BaseUrl is the URL that posts in ContentDir are served under, such as https://example.com/blog
+	BaseUrl string
+	// GoldmarkExtensions are the names of the Goldmark extensions used when rendering HTML. If nil, DefaultGoldmarkExtensions is used.
+	GoldmarkExtensions []string
+	// HighlightStyle is the Chroma style used for syntax highlighting. If empty, code blocks aren't highlighted.
+	HighlightStyle string
+	Transforms
+	PushHooks
 }
 
 func (m Markdown) GetName() string { return m.Name }
 func (m Markdown) GetType() string { return "markdown" }
 
+func (m Markdown) RewritesLinks() bool { return m.InternalLinks != "" }
+
+// Rewrite links to other posts on the source blog so they point at the Markdown copies of those posts
+func (m Markdown) RewriteLinks(data PostData, index LinkIndex) (PostData, []string) {
+	var target func(slug string, fragment string) string
+	switch m.InternalLinks {
+	case "ref":
+		target = func(slug string, fragment string) string {
+			if fragment != "" {
+				return fmt.Sprintf(`{{< ref "%s.md#%s" >}}`, slug, fragment)
+			}
+			return fmt.Sprintf(`{{< ref "%s.md" >}}`, slug)
+		}
+	case "url":
+		target = func(slug string, fragment string) string {
+			link := strings.TrimSuffix(m.BaseUrl, "/") + "/" + slug + "/"
+			if fragment != "" {
+				link += "#" + fragment
+			}
+			return link
+		}
+	default:
+		return data, nil
+	}
+	var unresolved []string
+	data.Markdown, unresolved = RewriteInternalLinks(data.Markdown, index, target)
+	return data, unresolved
+}
+
 // Push the data to the contentdir with the title as the filename using gosimple/slug.
 // The markdown file should have YAML frontmatter compatible with Hugo.
-func (m Markdown) Push(data PostData, options PushPullOptions) error {
+func (m Markdown) Push(data PostData, options PushPullOptions) (err error) {
 	// Create the file, if it exists, log an error and return
 	fs := afero.NewOsFs()
 	slug := slug.Make(data.Title)
 	// Clean the slug to remove any characters that may cause issues with the filesystem
 	slug = filepath.Clean(slug)
 	filePath := filepath.Join(m.ContentDir, slug+".md")
+	// If the Git directory is set, get the repository ready before changing anything
+	// When batching, this was done when the batch began
+	var baseBranch string
+	if m.GitDir != "" && options.GitBatch == nil {
+		baseBranch, err = m.PrepareGit(slug, options)
+		if err != nil {
+			return err
+		}
+		if m.Git.PostBranches {
+			defer func() {
+				if err != nil {
+					m.leavePostBranch(baseBranch)
+				}
+			}()
+		}
+	}
 	// Create parent directories if they don't exist
 	dirPath := filepath.Dir(filePath)
 	if _, err := fs.Stat(dirPath); os.IsNotExist(err) {
@@ -50,6 +105,8 @@ func (m Markdown) Push(data PostData, options PushPullOptions) error {
 			return errDir
 		}
 	}
+	// Keep the old version of the file so it can be put back if the pre_commit hook fails
+	previous, readErr := afero.ReadFile(fs, filePath)
 	// Check if the file already exists
 	if _, err := fs.Stat(filePath); err == nil && !m.Overwrite {
 		return fmt.Errorf("file already exists and overwrite is false for file: %s", filePath)
@@ -63,6 +120,10 @@ func (m Markdown) Push(data PostData, options PushPullOptions) error {
 		}
 	}
 
+	content, err := m.Preview(data)
+	if err != nil {
+		return err
+	}
 	// Create the file
 	file, err := fs.Create(filePath)
 	if err != nil {
@@ -70,42 +131,38 @@ func (m Markdown) Push(data PostData, options PushPullOptions) error {
 	}
 	// After the function returns, close the file
 	defer file.Close()
-	// Create the frontmatter
-	// Add the frontmatter fields that are selected
-	postFrontmatter := Frontmatter{
-		Title:        data.Title,
-		Description:  data.Description,
-		Categories:   data.Categories,
-		Tags:         data.Tags,
-		CanonicalUrl: data.CanonicalUrl,
-		Managed:      true,
+	log.Debug("Writing content", "content", content, "file", filePath)
+	_, err = file.WriteString(content)
+	if err != nil {
+		return err
 	}
-
-	// Only add Date if it's not the zero value
-	if !data.Date.IsZero() {
-		postFrontmatter.Date = data.Date.Format(time.RFC3339)
+	if err := file.Close(); err != nil {
+		return err
 	}
 
-	// Only add DateUpdated if it's not the zero value
-	if !data.DateUpdated.IsZero() {
-		postFrontmatter.DateUpdated = data.DateUpdated.Format(time.RFC3339)
-	}
-	// Convert the frontmatter to YAML
-	frontmatterYaml, err := yaml.Marshal(postFrontmatter.ToMap(m.FrontmatterMapping))
-	if err != nil {
-		return err
+	// The hook runs in the site's root so it can build the site with the new post
+	hookDir := m.GitDir
+	if hookDir == "" {
+		hookDir = m.ContentDir
 	}
-	// Write the frontmatter in between the delimiters
-	content := fmt.Sprintf("---\n%s---\n\n%s", frontmatterYaml, data.Markdown)
-	log.Debug("Writing content", "content", content, "file", filePath)
-	_, err = file.WriteString(content)
-	if err != nil {
+	if err := m.RunPreCommit(m, data, hookDir, filePath); err != nil {
+		// Put the old version of the file back so the failed post isn't left in the site
+		if readErr == nil {
+			if restoreErr := afero.WriteFile(fs, filePath, previous, 0644); restoreErr != nil {
+				log.Error("Failed to restore file", "file", filePath, "error", restoreErr)
+			}
+		} else if removeErr := fs.Remove(filePath); removeErr != nil {
+			log.Error("Failed to remove file", "file", filePath, "error", removeErr)
+		}
 		return err
 	}
 
 	// If the Git directory is set, commit + push the changes
-	if m.GitDir != "" {
-		commitHash, err := m.Commit(slug, true)
+	if m.GitDir != "" && options.GitBatch != nil {
+		options.GitBatch.Add(GitChange{Slug: slug, Post: data})
+		log.Debug("Added post to Git batch", "slug", slug)
+	} else if m.GitDir != "" {
+		commitHash, err := m.Commit(GitChange{Slug: slug, Post: data, Base: baseBranch}, true, options)
 		if err != nil {
 			return err
 		}
@@ -116,82 +173,51 @@ func (m Markdown) Push(data PostData, options PushPullOptions) error {
 
 }
 
-// Commit and optionally push the changes to the Git repository.
-// If contentDir is not a subdirectory of the gitDir, error.
-func (m Markdown) Commit(slug string, push bool) (hash string, err error) {
-	contentDir := m.ContentDir
-	gitDir := m.GitDir
-	filePath := filepath.Join(m.ContentDir, slug+".md")
-
-	// Clean the Git directory path
-	dirPath := filepath.Clean(gitDir)
-	// Clean the content directory path
-	contentDir = filepath.Clean(contentDir)
-	// Clean the file path
-	filePath = filepath.Clean(filePath)
-	// Make sure contentDir and gitDir are absolute paths
-	contentDir, err = filepath.Abs(contentDir)
-	if err != nil {
-		return "", err
-	}
-	gitDir, err = filepath.Abs(gitDir)
-	if err != nil {
-		return "", err
-	}
-	// Check if the contentDir is a subdirectory of the gitDir
-	// if !filepath.HasPrefix(contentDir, gitDir) {
-	// 	return "", fmt.Errorf("contentDir is not a subdirectory of gitDir")
-	// }
-	isSubdir, err := utils.IsSubdirectory(gitDir, contentDir)
-	if err != nil {
-		return "", err
-	}
-	if !isSubdir {
-		return "", fmt.Errorf("contentDir is not a subdirectory of gitDir")
-	}
-	// Open the repository
-	repo, err := git.PlainOpen(dirPath)
-	if err != nil {
-		return "", err
-	}
-	repoWorktree, err := repo.Worktree()
-	if err != nil {
-		return "", err
+// Preview returns the contents of the file the post would be written to: its frontmatter followed by its Markdown
+func (m Markdown) Preview(data PostData) (string, error) {
+	// Create the frontmatter
+	// Add the frontmatter fields that are selected
+	postFrontmatter := Frontmatter{
+		Title:        data.Title,
+		Description:  data.Description,
+		Categories:   data.Categories,
+		Tags:         data.Tags,
+		CanonicalUrl: data.CanonicalUrl,
+		Draft:        data.Draft,
+		Author:       data.Author,
+		Managed:      true,
 	}
-	// Get the relative path of filePath to dirPath
-	relativePath, err := filepath.Rel(dirPath, filePath)
-	if err != nil {
-		// Handle error, for example, return it
-		return "", err
+
+	// Only add Date if it's not the zero value
+	if !data.Date.IsZero() {
+		postFrontmatter.Date = data.Date.Format(time.RFC3339)
 	}
 
-	// Add the file
-	_, err = repoWorktree.Add(relativePath)
-	if err != nil {
-		return "", err
+	// Only add DateUpdated if it's not the zero value
+	if !data.DateUpdated.IsZero() {
+		postFrontmatter.DateUpdated = data.DateUpdated.Format(time.RFC3339)
 	}
-	// Commit the changes
-	commitHash, err := repoWorktree.Commit("Update "+slug+".md", &git.CommitOptions{})
+	// Convert the frontmatter to YAML
+	frontmatterYaml, err := yaml.Marshal(postFrontmatter.ToMap(m.FrontmatterMapping))
 	if err != nil {
 		return "", err
 	}
-	if push {
-		// Push the changes
-		err = repo.Push(&git.PushOptions{})
-		if err != nil {
-			return "", err
-		}
-	}
-	return commitHash.String(), nil
+	// Put the frontmatter in between the delimiters
+	return fmt.Sprintf("---\n%s---\n\n%s", frontmatterYaml, data.Markdown), nil
 }
 
+// ErrNoTitle is returned by ParseMarkdown for files without a title, such as Hugo's _index.md
+var ErrNoTitle = errors.New("title is not set in frontmatter")
+
 func (m Markdown) ParseMarkdown(markdown string) (markdownWithoutFrontmatter string, html string, frontmatterObject *Frontmatter, err error) {
 	err = nil
 	// Convert the markdown to HTML with Goldmark
-	// Use the Frontmatter extension to get the frontmatter
-	mdParser := goldmark.New(goldmark.WithExtensions(&goldmarkfrontmatter.Extender{
-		Mode: goldmarkfrontmatter.SetMetadata,
-	}))
+	// The Frontmatter extension is always used to get the frontmatter
+	extensions := m.GoldmarkExtensions
+	if extensions == nil {
+		extensions = DefaultGoldmarkExtensions
+	}
+	mdParser := newGoldmark(extensions, m.HighlightStyle)
 	var buf bytes.Buffer
 	parsedDoc := mdParser.Parser().Parse(text.NewReader([]byte(markdown)))
 	err = mdParser.Renderer().Render(&buf, []byte(markdown), parsedDoc)
@@ -205,23 +231,54 @@ func (m Markdown) ParseMarkdown(markdown string) (markdownWithoutFrontmatter str
 	}
 	// Check if title and canonical URL are set
 	if frontmatterObject.Title == "" {
-		return "", "", nil, err
+		return "", "", nil, ErrNoTitle
 	}
 	if frontmatterObject.CanonicalUrl == "" {
 		log.Debug("canonical_url is not set in frontmatter")
 	}
-	// Convert the HTML to Markdown
 	html = buf.String()
-	
-	// The frontmatter is stripped before converting to HTML
-	// Just convert the HTML to Markdown so the Markdown doesn't have the frontmatter (otherwise it would be duplicated)
-	markdownWithoutFrontmatter, err = md.NewConverter("", true, nil).ConvertString(html)
-	if err != nil {
-		return "", "", nil, err
-	}
+
+	// Take the body straight from the file rather than converting the HTML back to Markdown.
+	// This keeps shortcodes, raw HTML, reference links, and formatting exactly as they were written.
+	markdownWithoutFrontmatter = StripFrontmatter(markdown)
 	return
 }
 
+// Return the Markdown with the frontmatter (delimited by --- for YAML or +++ for TOML) removed.
+// Blank lines between the frontmatter and the body are removed as well but the body itself is left untouched.
+// If there's no frontmatter, the Markdown is returned as is.
+func StripFrontmatter(markdown string) string {
+	body := strings.TrimPrefix(markdown, "\ufeff")
+	var delimiter string
+	switch {
+	case strings.HasPrefix(body, "---\n"), strings.HasPrefix(body, "---\r\n"):
+		delimiter = "---"
+	case strings.HasPrefix(body, "+++\n"), strings.HasPrefix(body, "+++\r\n"):
+		delimiter = "+++"
+	default:
+		return markdown
+	}
+	// Skip the opening delimiter and look for a line that only contains the closing delimiter
+	offset := strings.Index(body, "\n") + 1
+	for offset < len(body) {
+		end := strings.Index(body[offset:], "\n")
+		var line string
+		if end == -1 {
+			line = body[offset:]
+			end = len(body) - offset
+		} else {
+			line = body[offset : offset+end]
+			end++
+		}
+		offset += end
+		if strings.TrimRight(line, " \t\r") == delimiter {
+			return strings.TrimLeft(body[offset:], "\r\n")
+		}
+	}
+	// The frontmatter was never closed, so it isn't frontmatter
+	return markdown
+}
+
 func (m Markdown) Pull(options PushPullOptions) (PostData, error) {
 	// Get the file path
 	fs := afero.NewOsFs()
@@ -242,12 +299,102 @@ func (m Markdown) Pull(options PushPullOptions) (PostData, error) {
 	if err != nil {
 		return PostData{}, err
 	}
+	// Dates that can't be parsed are left out rather than failing the pull
+	date, _ := parseFrontmatterDate(frontmatterObject.Date)
+	dateUpdated, _ := parseFrontmatterDate(frontmatterObject.DateUpdated)
 	return PostData{
 		Title:        frontmatterObject.Title,
 		Html:         html,
 		Markdown:     markdownWithoutFrontmatter,
+		Date:         date,
+		DateUpdated:  dateUpdated,
 		Description:  frontmatterObject.Description,
+		Categories:   frontmatterObject.Categories,
+		Tags:         frontmatterObject.Tags,
 		CanonicalUrl: frontmatterObject.CanonicalUrl,
+		Draft:        frontmatterObject.Draft,
+		Author:       frontmatterObject.Author,
 	}, nil
 
 }
+
+// List every Markdown file in the content directory (including subdirectories) that matches the filter, oldest first.
+// Files without a title in their frontmatter, such as Hugo's _index.md, are skipped.
+func (m Markdown) ListPosts(options PushPullOptions, filter PostFilter) ([]ListedPost, error) {
+	if m.ContentDir == "" {
+		return nil, fmt.Errorf("content_dir is required to list posts")
+	}
+	fs := afero.NewOsFs()
+	listed := []ListedPost{}
+	err := afero.Walk(fs, m.ContentDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".md" {
+			return nil
+		}
+		data, err := afero.ReadFile(fs, path)
+		if err != nil {
+			return err
+		}
+		_, _, frontmatterObject, err := m.ParseMarkdown(string(data))
+		if errors.Is(err, ErrNoTitle) {
+			log.Debug("Skipping file without a title", "file", path)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		relativePath, err := filepath.Rel(m.ContentDir, path)
+		if err != nil {
+			return err
+		}
+		date, err := parseFrontmatterDate(frontmatterObject.Date)
+		if err != nil {
+			log.Warn("Failed to parse date", "file", path, "date", frontmatterObject.Date, "error", err)
+		}
+		// Hugo doesn't build posts dated in the future, so treat them as scheduled
+		status := "live"
+		if frontmatterObject.Draft {
+			status = "draft"
+		} else if date.After(time.Now()) {
+			status = "scheduled"
+		}
+		post := ListedPost{
+			Id:     filepath.ToSlash(relativePath),
+			Title:  frontmatterObject.Title,
+			Date:   date,
+			Labels: append(append([]string{}, frontmatterObject.Categories...), frontmatterObject.Tags...),
+			Status: status,
+		}
+		if filter.Matches(post) {
+			listed = append(listed, post)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(listed, func(i, j int) bool { return listed[i].Date.Before(listed[j].Date) })
+	return listed, nil
+}
+
+// Pull a post returned by ListPosts
+func (m Markdown) PullListed(post ListedPost, options PushPullOptions) (PostData, error) {
+	options.Filepath = filepath.FromSlash(post.Id)
+	return m.Pull(options)
+}
+
+// Parse a date from frontmatter, which may be a full timestamp or just a date.
+// An empty string returns the zero time.
+func parseFrontmatterDate(date string) (time.Time, error) {
+	if date == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if parsed, err := time.Parse(layout, date); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date format: %s", date)
+}
@@ -0,0 +1,48 @@
+package platforms
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMarkdownPullTitle(t *testing.T) {
+	mapping, err := FrontmatterMappingFromInterface(map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		file      string
+		wantTitle string
+		wantErr   error
+	}{
+		{name: "title", file: "---\ntitle: Hello\ndate: 2024-01-01\n---\nbody", wantTitle: "Hello"},
+		{name: "no title", file: "---\ndate: 2024-01-01\n---\nbody", wantErr: ErrNoTitle},
+		{name: "empty title", file: "---\ntitle: \"\"\n---\nbody", wantErr: ErrNoTitle},
+		{name: "no frontmatter", file: "body", wantErr: ErrNoTitle},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "post.md"), []byte(tt.file), 0644); err != nil {
+				t.Fatal(err)
+			}
+			m := Markdown{ContentDir: dir, FrontmatterMapping: *mapping}
+			data, err := m.Pull(PushPullOptions{Filepath: "post.md"})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Pull() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Pull() error = %v", err)
+			}
+			if data.Title != tt.wantTitle {
+				t.Errorf("Pull() title = %q, want %q", data.Title, tt.wantTitle)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package platforms
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/log"
+	"github.com/go-resty/resty/v2"
+)
+
+// Used if the instance doesn't report its character limit
+const defaultMastodonCharacterLimit = 500
+
+// Mastodon counts every link as this many characters, no matter how long it is
+const mastodonUrlLength = 23
+
+// Mastodon announces new posts by posting a status to a Mastodon (or Mastodon-compatible) account
+type Mastodon struct {
+	Name string
+	// InstanceUrl is the URL of the account's instance, such as https://mastodon.social
+	InstanceUrl string
+	// Template is executed with an Announcement to make the text of the status
+	Template *template.Template
+	// Visibility is "public", "unlisted", "private", or "direct"
+	Visibility string
+	// CharacterLimit overrides the limit reported by the instance. If 0, the instance's limit is used.
+	CharacterLimit int
+	// PostIds remembers the status each post was announced in, so a post is only announced once
+	PostIds PostIds
+	Transforms
+	PushHooks
+}
+
+func (m Mastodon) GetName() string { return m.Name }
+func (m Mastodon) GetType() string { return "mastodon" }
+
+// Push posts a status announcing the post. Drafts and posts that were already announced aren't announced.
+func (m Mastodon) Push(data PostData, options PushPullOptions) error {
+	if data.Draft {
+		log.Info("Skipping draft since it can't be announced yet", "title", data.Title, "destination", m.Name)
+		return nil
+	}
+	if options.MastodonToken == "" {
+		return errors.New("a Mastodon access token is required")
+	}
+	defer m.PostIds.Lock(m.Name, data)()
+	announced, err := m.PostIds.Get(m.Name, data)
+	if err != nil {
+		return err
+	}
+	if announced != "" {
+		log.Info("Skipping post that was already announced", "title", data.Title, "destination", m.Name, "url", announced)
+		return nil
+	}
+	client := resty.New().SetBaseURL(strings.TrimSuffix(m.InstanceUrl, "/")).SetAuthToken(options.MastodonToken)
+
+	limit := m.CharacterLimit
+	if limit == 0 {
+		limit = m.instanceCharacterLimit(client)
+	}
+	status, err := renderAnnouncement(m.Template, data, limit, mastodonLength)
+	if err != nil {
+		return err
+	}
+
+	// The idempotency key stops the same post from being announced twice if the request is retried
+	idempotencyKey := sha256.Sum256([]byte(m.InstanceUrl + "\n" + data.CanonicalUrl + "\n" + data.Title))
+	resp, err := client.R().
+		SetHeader("Idempotency-Key", hex.EncodeToString(idempotencyKey[:])).
+		SetFormData(map[string]string{
+			"status":     status,
+			"visibility": m.Visibility,
+		}).
+		SetResult(&map[string]interface{}{}).
+		Post("/api/v1/statuses")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() != 200 {
+		return fmt.Errorf("failed to post status: %s", resp.String())
+	}
+	result := *resp.Result().(*map[string]interface{})
+	statusUrl, _ := result["url"].(string)
+	if statusUrl == "" {
+		statusUrl, _ = result["id"].(string)
+	}
+	log.Info("Posted status", "destination", m.Name, "url", statusUrl)
+	if err := m.PostIds.Set(m.Name, data, statusUrl); err != nil {
+		return fmt.Errorf("posted status %s but failed to record it in %s, so the post may be announced again: %w", statusUrl, m.PostIds.File, err)
+	}
+	return nil
+}
+
+// Return the character limit reported by the instance, falling back to Mastodon's default
+func (m Mastodon) instanceCharacterLimit(client *resty.Client) int {
+	var instance struct {
+		Configuration struct {
+			Statuses struct {
+				MaxCharacters int `json:"max_characters"`
+			} `json:"statuses"`
+		} `json:"configuration"`
+	}
+	resp, err := client.R().SetResult(&instance).Get("/api/v2/instance")
+	if err != nil || resp.StatusCode() != 200 || instance.Configuration.Statuses.MaxCharacters == 0 {
+		log.Debug("Couldn't get the instance's character limit. Using the default", "default", defaultMastodonCharacterLimit)
+		return defaultMastodonCharacterLimit
+	}
+	return instance.Configuration.Statuses.MaxCharacters
+}
+
+// Count the characters in a status the way Mastodon does, with every link counting as the same length
+func mastodonLength(text string) int {
+	length := utf8.RuneCountInString(text)
+	for _, link := range announcementLinkRegex.FindAllString(text, -1) {
+		length += mastodonUrlLength - utf8.RuneCountInString(link)
+	}
+	return length
+}
@@ -0,0 +1,93 @@
+package platforms
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestMastodonLength(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{name: "plain text", text: "hello", want: 5},
+		{name: "counts characters rather than bytes", text: "héllo wörld 🎉", want: 13},
+		{name: "links count as 23 characters", text: "see https://example.com/a/very/long/path/that/keeps/going.", want: 4 + mastodonUrlLength + 1},
+		{name: "short links count as 23 characters too", text: "https://a.io https://b.io", want: mastodonUrlLength*2 + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mastodonLength(tt.text); got != tt.want {
+				t.Errorf("mastodonLength(%q) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderAnnouncement(t *testing.T) {
+	tmpl := template.Must(template.New("template").Parse(DefaultAnnouncementTemplate))
+	url := "https://example.com/posts/a-post-with-a-rather-long-slug"
+	tests := []struct {
+		name    string
+		data    PostData
+		limit   int
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "fits",
+			data:  PostData{Title: "Hello", Description: "World", CanonicalUrl: url, Tags: []string{"go", "machine learning"}},
+			limit: 500,
+			want:  "Hello\n\nWorld\n\n" + url + "\n\n#go #MachineLearning",
+		},
+		{
+			name:  "drops the last hashtag first",
+			data:  PostData{Title: "Hello", Description: "World", CanonicalUrl: url, Tags: []string{"go", "rust"}},
+			limit: 45,
+			want:  "Hello\n\nWorld\n\n" + url + "\n\n#go",
+		},
+		{
+			name:  "drops every hashtag",
+			data:  PostData{Title: "Hello", Description: "World", CanonicalUrl: url, Tags: []string{"go", "rust"}},
+			limit: 40,
+			want:  "Hello\n\nWorld\n\n" + url,
+		},
+		{
+			name:  "shortens the description",
+			data:  PostData{Title: "Hello", Description: "The quick brown fox jumps"},
+			limit: 20,
+			want:  "Hello\n\nThe quick…",
+		},
+		{
+			name:    "title over the limit",
+			data:    PostData{Title: "A very long title"},
+			limit:   10,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderAnnouncement(tmpl, tt.data, tt.limit, mastodonLength)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("renderAnnouncement() = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("renderAnnouncement() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("renderAnnouncement() = %q, want %q", got, tt.want)
+			}
+			if length := mastodonLength(got); length > tt.limit {
+				t.Errorf("renderAnnouncement() is %d characters, over the limit of %d", length, tt.limit)
+			}
+			if strings.HasSuffix(got, "\n") {
+				t.Errorf("renderAnnouncement() = %q, should be trimmed", got)
+			}
+		})
+	}
+}
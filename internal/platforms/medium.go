@@ -0,0 +1,139 @@
+package platforms
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/go-resty/resty/v2"
+)
+
+// Medium allows up to five tags on a post, each up to 25 characters long
+const (
+	mediumMaxTags      = 5
+	mediumMaxTagLength = 25
+)
+
+// Medium publishes posts to Medium with an integration token.
+// Medium's API can only create posts, not update or delete them, so a post is only ever pushed once.
+// Overwriting isn't supported, and pushing a post that was already published returns an error rather than creating a duplicate.
+type Medium struct {
+	Name string
+	// ApiUrl is the URL of the API. It defaults to https://api.medium.com/v1.
+	ApiUrl string
+	// ContentFormat is "markdown" or "html"
+	ContentFormat string
+	// PublishStatus is "public", "draft", or "unlisted". Drafts are always pushed as drafts.
+	PublishStatus string
+	// If PublicationId is set, posts are published to the publication instead of the user's profile
+	PublicationId   string
+	NotifyFollowers bool
+	// PostIds remembers the URL of each published post
+	PostIds PostIds
+	Transforms
+	PushHooks
+}
+
+func (m Medium) GetName() string { return m.Name }
+func (m Medium) GetType() string { return "medium" }
+
+// Push creates the post on Medium, unless it was already published there
+func (m Medium) Push(data PostData, options PushPullOptions) error {
+	if options.MediumToken == "" {
+		return errors.New("a Medium integration token is required")
+	}
+	defer m.PostIds.Lock(m.Name, data)()
+	publishedUrl, err := m.PostIds.Get(m.Name, data)
+	if err != nil {
+		return err
+	}
+	if publishedUrl != "" {
+		return fmt.Errorf("%q was already published to Medium at %s, and Medium's API can't update posts. Edit it on Medium instead", data.Title, publishedUrl)
+	}
+	client := resty.New().SetBaseURL(strings.TrimSuffix(m.ApiUrl, "/")).SetAuthToken(options.MediumToken)
+
+	// Medium only uses the title for SEO, so it's added to the content as a heading to show it
+	content := "# " + data.Title + "\n\n" + data.Markdown
+	if m.ContentFormat == "html" {
+		content = "<h1>" + html.EscapeString(data.Title) + "</h1>\n" + data.Html
+	}
+	publishStatus := m.PublishStatus
+	if data.Draft {
+		publishStatus = "draft"
+	}
+	body := map[string]interface{}{
+		"title":           data.Title,
+		"contentFormat":   m.ContentFormat,
+		"content":         content,
+		"tags":            mediumTags(data.Tags),
+		"publishStatus":   publishStatus,
+		"notifyFollowers": m.NotifyFollowers,
+	}
+	if data.CanonicalUrl != "" {
+		body["canonicalUrl"] = data.CanonicalUrl
+	}
+
+	path := "/publications/" + m.PublicationId + "/posts"
+	if m.PublicationId == "" {
+		userId, err := mediumUserId(client)
+		if err != nil {
+			return err
+		}
+		path = "/users/" + userId + "/posts"
+	}
+	var result struct {
+		Data struct {
+			Id  string `json:"id"`
+			Url string `json:"url"`
+		} `json:"data"`
+	}
+	resp, err := client.R().SetBody(body).SetResult(&result).Post(path)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() != 201 {
+		return fmt.Errorf("failed to create Medium post: %s", resp.String())
+	}
+	log.Info("Published Medium post", "destination", m.Name, "url", result.Data.Url, "status", publishStatus)
+	if err := m.PostIds.Set(m.Name, data, result.Data.Url); err != nil {
+		// Without the URL, pushing the post again would publish a duplicate, so say what it was
+		return fmt.Errorf("published Medium post %s but failed to record it in %s, so add it there to avoid a duplicate: %w", result.Data.Url, m.PostIds.File, err)
+	}
+	return nil
+}
+
+// Return the ID of the user the token belongs to
+func mediumUserId(client *resty.Client) (string, error) {
+	var result struct {
+		Data struct {
+			Id string `json:"id"`
+		} `json:"data"`
+	}
+	resp, err := client.R().SetResult(&result).Get("/me")
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode() != 200 || result.Data.Id == "" {
+		return "", fmt.Errorf("failed to get Medium user: %s", resp.String())
+	}
+	return result.Data.Id, nil
+}
+
+// Return the tags Medium accepts: the first five that aren't too long
+func mediumTags(tags []string) []string {
+	mediumTags := []string{}
+	for _, tag := range tags {
+		if len([]rune(tag)) > mediumMaxTagLength {
+			log.Warn("Leaving out tag that's too long for Medium", "tag", tag)
+			continue
+		}
+		if len(mediumTags) == mediumMaxTags {
+			log.Warn("Medium only allows five tags. Leaving out the rest", "tags", tags)
+			break
+		}
+		mediumTags = append(mediumTags, tag)
+	}
+	return mediumTags
+}
@@ -0,0 +1,55 @@
+package platforms
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// The upper bounds, in seconds, of the buckets used for timing requests
+var requestBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Metrics about watching sources and pushing posts, served by the watch and serve commands
+var (
+	PollsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cross_blogger_polls_total",
+		Help: "Number of times a source was checked for new posts, by result.",
+	}, []string{"source", "result"})
+	NewPostsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cross_blogger_new_posts_total",
+		Help: "Number of new posts found while watching a source.",
+	}, []string{"source"})
+	LastSuccessfulPoll = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cross_blogger_last_successful_poll_timestamp_seconds",
+		Help: "Unix time of the last successful check of a source for new posts.",
+	}, []string{"source"})
+	PushesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cross_blogger_pushes_total",
+		Help: "Number of posts pushed to a destination, by result.",
+	}, []string{"destination", "type", "result"})
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cross_blogger_request_duration_seconds",
+		Help:    "How long calls to a source or destination's API took, such as checking for posts or pushing one.",
+		Buckets: requestBuckets,
+	}, []string{"platform", "operation"})
+)
+
+// Record how long an operation on a source or destination took since start
+func observeRequest(platform string, operation string, start time.Time) {
+	RequestDuration.WithLabelValues(platform, operation).Observe(time.Since(start).Seconds())
+}
+
+// Return the result label for an error
+func resultLabel(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// ObservePush records a push to a destination that started at start
+func ObservePush(destination Destination, start time.Time, err error) {
+	observeRequest(destination.GetName(), "push", start)
+	PushesTotal.WithLabelValues(destination.GetName(), destination.GetType(), resultLabel(err)).Inc()
+}
@@ -0,0 +1,228 @@
+package platforms
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/charmbracelet/log"
+	"github.com/go-resty/resty/v2"
+)
+
+// Micropub publishes posts to an IndieWeb site with the W3C Micropub protocol (https://www.w3.org/TR/micropub/)
+type Micropub struct {
+	Name string
+	// SiteUrl is the URL of the site. If Endpoint isn't set, it's discovered from the site's <link rel="micropub">.
+	SiteUrl  string
+	Endpoint string
+	// Format is "json" or "form". Posts are created in this format, while updates and deletes are always sent as JSON.
+	Format string
+	// PostIds remembers the URL of each created post so pushing it again updates it
+	PostIds PostIds
+	Transforms
+	PushHooks
+}
+
+func (m Micropub) GetName() string { return m.Name }
+func (m Micropub) GetType() string { return "micropub" }
+
+// Push creates the post as an h-entry or, if it was created on this destination before, replaces its properties
+func (m Micropub) Push(data PostData, options PushPullOptions) error {
+	if options.MicropubToken == "" {
+		return errors.New("a Micropub token is required")
+	}
+	endpoint, err := m.discoverEndpoint()
+	if err != nil {
+		return err
+	}
+	client := resty.New().SetAuthToken(options.MicropubToken)
+	properties := m.properties(data)
+
+	defer m.PostIds.Lock(m.Name, data)()
+	postUrl, err := m.PostIds.Get(m.Name, data)
+	if err != nil {
+		return err
+	}
+	if postUrl != "" {
+		resp, err := client.R().
+			SetBody(map[string]interface{}{
+				"action":  "update",
+				"url":     postUrl,
+				"replace": properties,
+			}).
+			Post(endpoint)
+		if err != nil {
+			return err
+		}
+		if !resp.IsSuccess() {
+			return fmt.Errorf("failed to update Micropub post %s: %s", postUrl, resp.String())
+		}
+		log.Info("Updated Micropub post", "destination", m.Name, "url", postUrl)
+		return nil
+	}
+
+	request := client.R()
+	if m.Format == "form" {
+		// Form-encoded requests only have plain text content, so the Markdown is sent
+		form := url.Values{"h": {"entry"}}
+		for property, values := range properties {
+			for _, value := range values {
+				if property == "content" {
+					value = data.Markdown
+				}
+				key := property
+				if len(values) > 1 || property == "category" || property == "syndication" {
+					key += "[]"
+				}
+				form.Add(key, fmt.Sprint(value))
+			}
+		}
+		request.SetFormDataFromValues(form)
+	} else {
+		request.SetBody(map[string]interface{}{
+			"type":       []string{"h-entry"},
+			"properties": properties,
+		})
+	}
+	resp, err := request.Post(endpoint)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() != 201 && resp.StatusCode() != 202 {
+		return fmt.Errorf("failed to create Micropub post: %s", resp.String())
+	}
+	location := resp.Header().Get("Location")
+	if location == "" {
+		log.Warn("Micropub endpoint didn't return the URL of the post, so it can't be updated later", "destination", m.Name)
+		return nil
+	}
+	log.Info("Created Micropub post", "destination", m.Name, "url", location)
+	if err := m.PostIds.Set(m.Name, data, location); err != nil {
+		// Without the URL, pushing the post again would create a duplicate, so say what it was
+		return fmt.Errorf("created Micropub post %s but failed to record it in %s, so add it there to avoid a duplicate: %w", location, m.PostIds.File, err)
+	}
+	return nil
+}
+
+// Delete deletes the post from the site.
+// If the post wasn't created by this destination, its canonical URL is assumed to be its URL on the site.
+func (m Micropub) Delete(data PostData, options PushPullOptions) error {
+	if options.MicropubToken == "" {
+		return errors.New("a Micropub token is required")
+	}
+	endpoint, err := m.discoverEndpoint()
+	if err != nil {
+		return err
+	}
+	defer m.PostIds.Lock(m.Name, data)()
+	postUrl, err := m.PostIds.Get(m.Name, data)
+	if err != nil {
+		return err
+	}
+	if postUrl == "" {
+		postUrl = data.CanonicalUrl
+	}
+	if postUrl == "" {
+		return fmt.Errorf("the URL of %q on %s isn't known", data.Title, m.Name)
+	}
+	resp, err := resty.New().SetAuthToken(options.MicropubToken).R().
+		SetBody(map[string]interface{}{
+			"action": "delete",
+			"url":    postUrl,
+		}).
+		Post(endpoint)
+	if err != nil {
+		return err
+	}
+	if !resp.IsSuccess() {
+		return fmt.Errorf("failed to delete Micropub post %s: %s", postUrl, resp.String())
+	}
+	log.Info("Deleted Micropub post", "destination", m.Name, "url", postUrl)
+	return m.PostIds.Delete(m.Name, data)
+}
+
+// Build the h-entry properties for the post. In JSON, every property is an array.
+func (m Micropub) properties(data PostData) map[string][]interface{} {
+	properties := map[string][]interface{}{
+		"name":    {data.Title},
+		"content": {map[string]string{"html": data.Html}},
+	}
+	if data.Description != "" {
+		properties["summary"] = []interface{}{data.Description}
+	}
+	categories := []interface{}{}
+	for _, category := range append(append([]string{}, data.Categories...), data.Tags...) {
+		categories = append(categories, category)
+	}
+	if len(categories) > 0 {
+		properties["category"] = categories
+	}
+	if !data.Date.IsZero() {
+		properties["published"] = []interface{}{data.Date.Format(time.RFC3339)}
+	}
+	if data.CanonicalUrl != "" {
+		properties["syndication"] = []interface{}{data.CanonicalUrl}
+	}
+	if data.Draft {
+		properties["post-status"] = []interface{}{"draft"}
+	}
+	return properties
+}
+
+// Matches a Link header with rel="micropub", such as <https://example.com/micropub>; rel="micropub"
+var micropubLinkHeaderRegex = regexp.MustCompile(`<([^>]*)>\s*;[^,]*rel="?(?:[^",]*\s)?micropub(?:\s[^",]*)?"?`)
+
+// Return the Micropub endpoint, discovering it from the site's Link header or <link rel="micropub"> if it isn't set
+func (m Micropub) discoverEndpoint() (string, error) {
+	if m.Endpoint != "" {
+		return m.Endpoint, nil
+	}
+	resp, err := resty.New().R().Get(m.SiteUrl)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode() != 200 {
+		return "", fmt.Errorf("failed to fetch %s to discover the Micropub endpoint: %s", m.SiteUrl, resp.Status())
+	}
+	var endpoint string
+	for _, header := range resp.Header().Values("Link") {
+		if match := micropubLinkHeaderRegex.FindStringSubmatch(header); match != nil {
+			endpoint = match[1]
+			break
+		}
+	}
+	if endpoint == "" {
+		document, err := goquery.NewDocumentFromReader(strings.NewReader(resp.String()))
+		if err != nil {
+			return "", err
+		}
+		document.Find("link[href], a[href]").EachWithBreak(func(_ int, link *goquery.Selection) bool {
+			rel, _ := link.Attr("rel")
+			for _, value := range strings.Fields(rel) {
+				if value == "micropub" {
+					endpoint, _ = link.Attr("href")
+					return false
+				}
+			}
+			return true
+		})
+	}
+	if endpoint == "" {
+		return "", fmt.Errorf("no Micropub endpoint found on %s", m.SiteUrl)
+	}
+	// The endpoint can be relative to the site
+	base, err := url.Parse(resp.RawResponse.Request.URL.String())
+	if err != nil {
+		return "", err
+	}
+	resolved, err := base.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	log.Debug("Discovered Micropub endpoint", "site", m.SiteUrl, "endpoint", resolved.String())
+	return resolved.String(), nil
+}
@@ -3,6 +3,7 @@ package platforms
 import (
 	"fmt"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -14,6 +15,18 @@ type Destination interface {
 	GetType() string
 }
 
+// DeletableDestination is a destination that posts can be deleted from
+type DeletableDestination interface {
+	Destination
+	Delete(PostData, PushPullOptions) error
+}
+
+// PreviewableDestination is a destination that can show what it would write for a post without pushing it
+type PreviewableDestination interface {
+	Destination
+	Preview(PostData) (string, error)
+}
+
 type Source interface {
 	Pull(PushPullOptions) (PostData, error)
 	GetName() string
@@ -21,8 +34,8 @@ type Source interface {
 }
 type WatchableSource interface {
 	Source
-	// Watch(time.Duration, PushPullOptions, chan<- PostData, chan<- error)
-	Watch(*sync.WaitGroup, time.Duration, PushPullOptions, chan<- PostData, chan<- error)
+	// Watch sends the new posts found each interval to the channel as one slice, so they can be committed together
+	Watch(*sync.WaitGroup, time.Duration, PushPullOptions, chan<- []PostData, chan<- error)
 	CleanMarkdownPosts(*sync.WaitGroup, time.Duration, *Markdown, PushPullOptions, chan<- error)
 }
 
@@ -38,19 +51,62 @@ type PushPullOptions struct {
 	LlmBaseUrl   string
 	LlmApiKey    string
 	LlmModel     string
+	// ForgeToken is used to open pull requests when a Markdown destination is in review mode
+	ForgeToken string
+	// Git credentials for Markdown destinations
+	GitToken             string
+	GitSshPassphrase     string
+	GitSigningPassphrase string
+	// If set, Markdown destinations add their changes to the batch instead of committing them
+	GitBatch *GitBatch
+	// MastodonToken is the access token of the account Mastodon destinations post as
+	MastodonToken string
+	// The session Bluesky destinations post with, from Bluesky.Authorize
+	BlueskyAccessToken string
+	BlueskyDid         string
+	// HashnodeToken is the personal access token Hashnode destinations publish with
+	HashnodeToken string
+	// MediumToken is the integration token Medium destinations publish with
+	MediumToken string
+	// MicropubToken is the IndieAuth bearer token Micropub destinations publish with
+	MicropubToken string
+	// WebhookSecret is used to sign the bodies sent by webhook destinations
+	WebhookSecret string
 }
 
 type PostData struct {
-	Title       string
-	Html        string
-	Markdown    string
-	Date        time.Time
-	DateUpdated time.Time
-	Description string
-	Categories  []string
-	Tags        []string
+	Title       string    `json:"title"`
+	Html        string    `json:"html"`
+	Markdown    string    `json:"markdown"`
+	Date        time.Time `json:"date"`
+	DateUpdated time.Time `json:"date_updated"`
+	Description string    `json:"description"`
+	Categories  []string  `json:"categories"`
+	Tags        []string  `json:"tags"`
 	// Other fields that are probably needed are canonical URL, publish date, and description
-	CanonicalUrl string
+	CanonicalUrl string `json:"canonical_url"`
+	// Draft is true for posts that haven't been published
+	Draft bool `json:"draft"`
+	// Comments left on the post, if the source has them
+	Comments []Comment `json:"comments,omitempty"`
+	// Author is the display name of whoever wrote the post, if the source has it
+	Author string `json:"author,omitempty"`
+	// Attachments are files, such as images, uploaded alongside the post
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// Source is the name of the source the post was pulled from
+	Source string `json:"source,omitempty"`
+}
+
+type Comment struct {
+	Author    string    `json:"author"`
+	AuthorUrl string    `json:"author_url,omitempty"`
+	Date      time.Time `json:"date"`
+	Html      string    `json:"html"`
+}
+
+type Attachment struct {
+	Url   string `json:"url"`
+	Title string `json:"title,omitempty"`
 }
 
 type Blogger struct {
@@ -61,6 +117,12 @@ type Blogger struct {
 	Overwrite               bool
 	GenerateLlmDescriptions bool
 	knownPosts              []string
+	Transforms
+	PushHooks
+	// Attribution, if set, is added to pushed posts since Blogger can't set a canonical URL
+	Attribution *Attribution
+	// Embeds are converted to shortcodes when pulling and back to HTML when pushing
+	Embeds []EmbedRule
 }
 
 func CreateDestination(destMap map[string]interface{}) (Destination, error) {
@@ -69,6 +131,16 @@ func CreateDestination(destMap map[string]interface{}) (Destination, error) {
 	if !ok || name == "" {
 		return nil, fmt.Errorf("name is required")
 	}
+	// Every destination can have a chain of transforms that are run before pushing
+	transforms, err := TransformsFromInterface(destMap["transforms"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid transforms for %s: %w", name, err)
+	}
+	// Every destination can also run commands before and after pushing
+	pushHooks, err := PushHooksFromInterface(destMap)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hooks for %s: %w", name, err)
+	}
 
 	switch destMap["type"] {
 	case "blogger":
@@ -80,11 +152,26 @@ func CreateDestination(destMap map[string]interface{}) (Destination, error) {
 
 		// Optionally, enable LLM generated descriptions
 		// If not set or not a bool, defaults to false
-		overwrite, _ := destMap["overwrite"].(bool) 
+		overwrite, _ := destMap["overwrite"].(bool)
+		// Optionally, add an "Originally published at" block since Blogger can't set a canonical URL
+		attribution, err := AttributionFromInterface(destMap["attribution"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid attribution for %s: %w", name, err)
+		}
+		// Optionally, convert Hugo shortcodes back into embeds
+		shortcodes, _ := destMap["shortcodes"].(bool)
+		embeds, err := EmbedRulesFromInterface(shortcodes, destMap["embed_rules"])
+		if err != nil {
+			return nil, err
+		}
 		return &Blogger{
-			Name:      name,
-			BlogUrl:   blogUrl,
-			Overwrite: overwrite,
+			Name:        name,
+			BlogUrl:     blogUrl,
+			Overwrite:   overwrite,
+			Transforms:  transforms,
+			PushHooks:   pushHooks,
+			Attribution: attribution,
+			Embeds:      embeds,
 		}, nil
 	case "markdown":
 		contentDir, ok := destMap["content_dir"].(string)
@@ -92,6 +179,10 @@ func CreateDestination(destMap map[string]interface{}) (Destination, error) {
 			return nil, fmt.Errorf("content_dir is required for markdown")
 		}
 		gitDir, _ := destMap["git_dir"].(string) // If not set, defaults to ""
+		gitOptions, err := GitOptionsFromInterface(destMap["git"])
+		if err != nil {
+			return nil, err
+		}
 		// Assert that frontmatter_mapping is a map of strings to strings
 		frontmatterMapping, err := FrontmatterMappingFromInterface(destMap["frontmatter_mapping"])
 		if err != nil {
@@ -102,13 +193,326 @@ func CreateDestination(destMap map[string]interface{}) (Destination, error) {
 			}
 		}
 		overwrite, _ := destMap["overwrite"].(bool) // If not set or not a bool, defaults to false
+		// Optionally, rewrite links to other posts on the source blog
+		internalLinks, _ := destMap["internal_links"].(string)
+		baseUrl, _ := destMap["base_url"].(string)
+		switch internalLinks {
+		case "", "ref":
+		case "url":
+			if baseUrl == "" {
+				return nil, fmt.Errorf("base_url is required when internal_links is \"url\"")
+			}
+		default:
+			return nil, fmt.Errorf("unknown internal_links mode: %s", internalLinks)
+		}
 
 		return &Markdown{
 			Name:               name,
 			ContentDir:         contentDir,
 			GitDir:             gitDir,
+			Git:                gitOptions,
 			FrontmatterMapping: *frontmatterMapping,
 			Overwrite:          overwrite,
+			InternalLinks:      internalLinks,
+			BaseUrl:            baseUrl,
+			Transforms:         transforms,
+			PushHooks:          pushHooks,
+		}, nil
+	case "wxr":
+		file, ok := destMap["file"].(string)
+		if !ok || file == "" {
+			return nil, fmt.Errorf("file is required for wxr")
+		}
+		blogUrl, _ := destMap["blog_url"].(string)
+		if err := validateWxrBlogUrl(blogUrl); err != nil {
+			return nil, err
+		}
+		author, _ := destMap["author"].(string)
+		overwrite, _ := destMap["overwrite"].(bool)
+		return &Wxr{
+			Name:       name,
+			File:       file,
+			BlogUrl:    blogUrl,
+			Author:     author,
+			Overwrite:  overwrite,
+			Transforms: transforms,
+			PushHooks:  pushHooks,
+		}, nil
+	case "feed":
+		file, ok := destMap["file"].(string)
+		if !ok || file == "" {
+			return nil, fmt.Errorf("file is required for feed")
+		}
+		format, _ := destMap["format"].(string)
+		switch format {
+		case "rss", "atom", "json":
+		case "":
+			return nil, fmt.Errorf("format is required for feed")
+		default:
+			return nil, fmt.Errorf("unknown feed format: %s", format)
+		}
+		title, _ := destMap["title"].(string)
+		link, _ := destMap["link"].(string)
+		description, _ := destMap["description"].(string)
+		feedUrl, _ := destMap["feed_url"].(string)
+		// If not set, keep the newest 20 entries
+		maxItems := 20
+		if _, ok := destMap["max_items"]; ok {
+			maxItems, err = intFromInterface(destMap["max_items"])
+			if err != nil || maxItems < 0 {
+				return nil, fmt.Errorf("max_items must be a number that's 0 or more")
+			}
+		}
+		gitDir, _ := destMap["git_dir"].(string)
+		gitOptions, err := GitOptionsFromInterface(destMap["git"])
+		if err != nil {
+			return nil, err
+		}
+		// Unless a commit message is set, mention the feed rather than the post's file
+		if gitMap, ok := destMap["git"].(map[string]interface{}); !ok || gitMap["commit_message"] == nil {
+			gitOptions.CommitMessage = template.Must(template.New("commit_message").Parse(DefaultFeedCommitMessage))
+		}
+		return &Feed{
+			Name:        name,
+			File:        file,
+			Format:      format,
+			Title:       title,
+			Link:        link,
+			Description: description,
+			FeedUrl:     feedUrl,
+			MaxItems:    maxItems,
+			GitDir:      gitDir,
+			Git:         gitOptions,
+			Transforms:  transforms,
+			PushHooks:   pushHooks,
+		}, nil
+	case "mastodon":
+		instanceUrl, ok := destMap["instance_url"].(string)
+		if !ok || instanceUrl == "" {
+			return nil, fmt.Errorf("instance_url is required for mastodon")
+		}
+		statusTemplate, ok := destMap["template"].(string)
+		if !ok || statusTemplate == "" {
+			statusTemplate = DefaultAnnouncementTemplate
+		}
+		parsedTemplate, err := template.New("template").Parse(statusTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template: %w", err)
+		}
+		visibility, ok := destMap["visibility"].(string)
+		if !ok || visibility == "" {
+			visibility = "public"
+		}
+		switch visibility {
+		case "public", "unlisted", "private", "direct":
+		default:
+			return nil, fmt.Errorf("unknown visibility: %s", visibility)
+		}
+		// If not set, the instance's limit is used
+		var characterLimit int
+		if _, ok := destMap["character_limit"]; ok {
+			characterLimit, err = intFromInterface(destMap["character_limit"])
+			if err != nil || characterLimit <= 0 {
+				return nil, fmt.Errorf("character_limit must be a number greater than 0")
+			}
+		}
+		// Announced posts are remembered so they aren't announced again
+		postIdsFile, ok := destMap["post_ids_file"].(string)
+		if !ok || postIdsFile == "" {
+			postIdsFile = DefaultPostIdsFile
+		}
+		return &Mastodon{
+			Name:           name,
+			InstanceUrl:    instanceUrl,
+			Template:       parsedTemplate,
+			Visibility:     visibility,
+			CharacterLimit: characterLimit,
+			PostIds:        PostIds{File: postIdsFile},
+			Transforms:     transforms,
+			PushHooks:      pushHooks,
+		}, nil
+	case "bluesky":
+		handle, ok := destMap["handle"].(string)
+		if !ok || handle == "" {
+			return nil, fmt.Errorf("handle is required for bluesky")
+		}
+		service, ok := destMap["service"].(string)
+		if !ok || service == "" {
+			service = "https://bsky.social"
+		}
+		postTemplate, ok := destMap["template"].(string)
+		if !ok || postTemplate == "" {
+			postTemplate = DefaultAnnouncementTemplate
+		}
+		parsedTemplate, err := template.New("template").Parse(postTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template: %w", err)
+		}
+		languages := []string{}
+		if languageSlice, ok := destMap["languages"].([]interface{}); ok {
+			for _, language := range languageSlice {
+				languageString, ok := language.(string)
+				if !ok {
+					return nil, fmt.Errorf("languages must be strings")
+				}
+				languages = append(languages, languageString)
+			}
+		}
+		// Announced posts are remembered so they aren't announced again
+		postIdsFile, ok := destMap["post_ids_file"].(string)
+		if !ok || postIdsFile == "" {
+			postIdsFile = DefaultPostIdsFile
+		}
+		return &Bluesky{
+			Name:       name,
+			Service:    service,
+			Handle:     handle,
+			Template:   parsedTemplate,
+			Languages:  languages,
+			PostIds:    PostIds{File: postIdsFile},
+			Transforms: transforms,
+			PushHooks:  pushHooks,
+		}, nil
+	case "hashnode":
+		publicationId, _ := destMap["publication_id"].(string)
+		publicationHost, _ := destMap["publication_host"].(string)
+		if publicationId == "" && publicationHost == "" {
+			return nil, fmt.Errorf("publication_id or publication_host is required for hashnode")
+		}
+		apiUrl, ok := destMap["api_url"].(string)
+		if !ok || apiUrl == "" {
+			apiUrl = "https://gql.hashnode.com"
+		}
+		coverImage, _ := destMap["cover_image"].(bool)
+		postIdsFile, ok := destMap["post_ids_file"].(string)
+		if !ok || postIdsFile == "" {
+			postIdsFile = DefaultPostIdsFile
+		}
+		return &Hashnode{
+			Name:            name,
+			ApiUrl:          apiUrl,
+			PublicationId:   publicationId,
+			PublicationHost: publicationHost,
+			CoverImage:      coverImage,
+			PostIds:         PostIds{File: postIdsFile},
+			Transforms:      transforms,
+			PushHooks:       pushHooks,
+		}, nil
+	case "medium":
+		// Medium's API can't update or delete posts
+		if overwrite, _ := destMap["overwrite"].(bool); overwrite {
+			return nil, fmt.Errorf("overwrite isn't supported for medium since Medium's API can only create posts")
+		}
+		contentFormat, ok := destMap["content_format"].(string)
+		if !ok || contentFormat == "" {
+			contentFormat = "markdown"
+		}
+		if contentFormat != "markdown" && contentFormat != "html" {
+			return nil, fmt.Errorf("unknown content_format: %s", contentFormat)
+		}
+		publishStatus, ok := destMap["publish_status"].(string)
+		if !ok || publishStatus == "" {
+			publishStatus = "public"
+		}
+		switch publishStatus {
+		case "public", "draft", "unlisted":
+		default:
+			return nil, fmt.Errorf("unknown publish_status: %s", publishStatus)
+		}
+		apiUrl, ok := destMap["api_url"].(string)
+		if !ok || apiUrl == "" {
+			apiUrl = "https://api.medium.com/v1"
+		}
+		publicationId, _ := destMap["publication_id"].(string)
+		notifyFollowers, _ := destMap["notify_followers"].(bool)
+		postIdsFile, ok := destMap["post_ids_file"].(string)
+		if !ok || postIdsFile == "" {
+			postIdsFile = DefaultPostIdsFile
+		}
+		return &Medium{
+			Name:            name,
+			ApiUrl:          apiUrl,
+			ContentFormat:   contentFormat,
+			PublishStatus:   publishStatus,
+			PublicationId:   publicationId,
+			NotifyFollowers: notifyFollowers,
+			PostIds:         PostIds{File: postIdsFile},
+			Transforms:      transforms,
+			PushHooks:       pushHooks,
+		}, nil
+	case "micropub":
+		siteUrl, _ := destMap["site_url"].(string)
+		endpoint, _ := destMap["endpoint"].(string)
+		if siteUrl == "" && endpoint == "" {
+			return nil, fmt.Errorf("site_url or endpoint is required for micropub")
+		}
+		format, ok := destMap["format"].(string)
+		if !ok || format == "" {
+			format = "json"
+		}
+		if format != "json" && format != "form" {
+			return nil, fmt.Errorf("unknown micropub format: %s", format)
+		}
+		postIdsFile, ok := destMap["post_ids_file"].(string)
+		if !ok || postIdsFile == "" {
+			postIdsFile = DefaultPostIdsFile
+		}
+		return &Micropub{
+			Name:       name,
+			SiteUrl:    siteUrl,
+			Endpoint:   endpoint,
+			Format:     format,
+			PostIds:    PostIds{File: postIdsFile},
+			Transforms: transforms,
+			PushHooks:  pushHooks,
+		}, nil
+	case "webhook":
+		webhookUrl, ok := destMap["url"].(string)
+		if !ok || webhookUrl == "" {
+			return nil, fmt.Errorf("url is required for webhook")
+		}
+		headers := map[string]string{}
+		if headerMap, ok := destMap["headers"].(map[string]interface{}); ok {
+			for header, value := range headerMap {
+				valueString, ok := value.(string)
+				if !ok {
+					return nil, fmt.Errorf("header %s must be a string", header)
+				}
+				headers[header] = valueString
+			}
+		}
+		var bodyTemplate *template.Template
+		contentType := "application/json"
+		if body, ok := destMap["body_template"].(string); ok && body != "" {
+			bodyTemplate, err = template.New("body_template").Funcs(webhookTemplateFuncs).Parse(body)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse body_template: %w", err)
+			}
+		}
+		if configuredContentType, ok := destMap["content_type"].(string); ok && configuredContentType != "" {
+			contentType = configuredContentType
+		}
+		retries := 3
+		if _, ok := destMap["retries"]; ok {
+			retries, err = intFromInterface(destMap["retries"])
+			if err != nil || retries < 0 {
+				return nil, fmt.Errorf("retries must be a number that's 0 or more")
+			}
+		}
+		postIdsFile, ok := destMap["post_ids_file"].(string)
+		if !ok || postIdsFile == "" {
+			postIdsFile = DefaultPostIdsFile
+		}
+		return &Webhook{
+			Name:         name,
+			Url:          webhookUrl,
+			Headers:      headers,
+			BodyTemplate: bodyTemplate,
+			ContentType:  contentType,
+			Retries:      retries,
+			PostIds:      PostIds{File: postIdsFile},
+			Transforms:   transforms,
+			PushHooks:    pushHooks,
 		}, nil
 	default:
 		return nil, fmt.Errorf("unknown destination type: %s", destMap["type"])
@@ -136,11 +540,61 @@ func CreateSource(sourceMap map[string]interface{}) (Source, error) {
 			categoryPrefix = "category::"
 		}
 		generateLlmDescriptions, _ := sourceMap["generate_llm_descriptions"].(bool)
+		// Optionally, convert embeds (such as YouTube iframes) into Hugo shortcodes
+		shortcodes, _ := sourceMap["shortcodes"].(bool)
+		embeds, err := EmbedRulesFromInterface(shortcodes, sourceMap["embed_rules"])
+		if err != nil {
+			return nil, err
+		}
 		return &Blogger{
 			Name:                    name,
 			BlogUrl:                 blogUrl,
 			GenerateLlmDescriptions: generateLlmDescriptions,
 			CategoryPrefix:          categoryPrefix,
+			Embeds:                  embeds,
+		}, nil
+	case "blogger-export":
+		file, ok := sourceMap["file"].(string)
+		if !ok || file == "" {
+			return nil, fmt.Errorf("file is required for blogger-export")
+		}
+		// Optional, since the export has the blog's URL
+		blogUrl, _ := sourceMap["blog_url"].(string)
+		categoryPrefix, ok := sourceMap["category_prefix"].(string)
+		if !ok || categoryPrefix == "" {
+			log.Warn("category_prefix is not a string or is empty. Using default", "default", "category::")
+			categoryPrefix = "category::"
+		}
+		generateLlmDescriptions, _ := sourceMap["generate_llm_descriptions"].(bool)
+		shortcodes, _ := sourceMap["shortcodes"].(bool)
+		embeds, err := EmbedRulesFromInterface(shortcodes, sourceMap["embed_rules"])
+		if err != nil {
+			return nil, err
+		}
+		return &BloggerExport{
+			Name:                    name,
+			File:                    file,
+			BlogUrl:                 blogUrl,
+			CategoryPrefix:          categoryPrefix,
+			GenerateLlmDescriptions: generateLlmDescriptions,
+			Embeds:                  embeds,
+		}, nil
+	case "wxr":
+		file, ok := sourceMap["file"].(string)
+		if !ok || file == "" {
+			return nil, fmt.Errorf("file is required for wxr")
+		}
+		blogUrl, _ := sourceMap["blog_url"].(string)
+		shortcodes, _ := sourceMap["shortcodes"].(bool)
+		embeds, err := EmbedRulesFromInterface(shortcodes, sourceMap["embed_rules"])
+		if err != nil {
+			return nil, err
+		}
+		return &Wxr{
+			Name:    name,
+			File:    file,
+			BlogUrl: blogUrl,
+			Embeds:  embeds,
 		}, nil
 	case "markdown":
 		// If the content_dir is not set, set it to null as its not required
@@ -154,10 +608,32 @@ func CreateSource(sourceMap map[string]interface{}) (Source, error) {
 				return nil, err
 			}
 		}
+		// Optionally, choose which Goldmark extensions are used when rendering HTML
+		var goldmarkExtensions []string
+		if sourceMap["goldmark_extensions"] != nil {
+			goldmarkExtensions, err = stringSliceFromInterface(sourceMap["goldmark_extensions"])
+			if err != nil {
+				return nil, fmt.Errorf("goldmark_extensions: %w", err)
+			}
+		}
+		highlightStyle, _ := sourceMap["syntax_highlighting"].(string)
+		if err := validateGoldmarkOptions(goldmarkExtensions, highlightStyle); err != nil {
+			return nil, err
+		}
+		// If git_dir is set, the serve command pulls the repository before reading posts that were pushed to it
+		gitDir, _ := sourceMap["git_dir"].(string)
+		gitOptions, err := GitOptionsFromInterface(sourceMap["git"])
+		if err != nil {
+			return nil, err
+		}
 		return &Markdown{
 			Name:               name,
 			ContentDir:         contentDir,
+			GitDir:             gitDir,
+			Git:                gitOptions,
 			FrontmatterMapping: *frontmatterMapping,
+			GoldmarkExtensions: goldmarkExtensions,
+			HighlightStyle:     highlightStyle,
 		}, nil
 	default:
 		return nil, fmt.Errorf("unknown source type: %s", sourceMap["type"])
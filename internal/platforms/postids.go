@@ -0,0 +1,114 @@
+package platforms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/gosimple/slug"
+)
+
+// The default file for remembering the IDs posts were given by destinations
+const DefaultPostIdsFile = "post_ids.json"
+
+// PostIds remembers the ID each post was given by API destinations, so later pushes can update the post instead of creating another one.
+// The file maps the name of each destination to a map of post keys to IDs.
+type PostIds struct {
+	File string
+}
+
+// Reading and writing the file isn't atomic, so only one destination uses it at a time
+var postIdsLock sync.Mutex
+
+// Locks for pushing a post to a destination, keyed by the destination's name and the post's key
+var postLocks sync.Map
+
+// Lock stops other pushes of the post to the destination until the returned function is called.
+// Destinations hold it from looking up the post's ID until the new ID is recorded, so concurrent pushes (such as from serve or import) can't both create the post.
+func (p PostIds) Lock(destination string, data PostData) (unlock func()) {
+	value, _ := postLocks.LoadOrStore(destination+"\n"+PostKey(data), &sync.Mutex{})
+	lock := value.(*sync.Mutex)
+	lock.Lock()
+	return lock.Unlock
+}
+
+// PostKey returns the key a post is remembered by: its canonical URL, or the slug of its title if it doesn't have one
+func PostKey(data PostData) string {
+	if data.CanonicalUrl != "" {
+		return data.CanonicalUrl
+	}
+	return slug.Make(data.Title)
+}
+
+// Get returns the ID the destination gave the post, or an empty string if it hasn't been pushed there
+func (p PostIds) Get(destination string, data PostData) (string, error) {
+	postIdsLock.Lock()
+	defer postIdsLock.Unlock()
+	ids, err := p.read()
+	if err != nil {
+		return "", err
+	}
+	return ids[destination][PostKey(data)], nil
+}
+
+// Set records the ID the destination gave the post
+func (p PostIds) Set(destination string, data PostData, id string) error {
+	postIdsLock.Lock()
+	defer postIdsLock.Unlock()
+	ids, err := p.read()
+	if err != nil {
+		return err
+	}
+	if ids[destination] == nil {
+		ids[destination] = map[string]string{}
+	}
+	ids[destination][PostKey(data)] = id
+	return p.write(ids)
+}
+
+// Delete forgets the ID the destination gave the post
+func (p PostIds) Delete(destination string, data PostData) error {
+	postIdsLock.Lock()
+	defer postIdsLock.Unlock()
+	ids, err := p.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := ids[destination][PostKey(data)]; !ok {
+		return nil
+	}
+	delete(ids[destination], PostKey(data))
+	return p.write(ids)
+}
+
+// Read the file. If it doesn't exist yet, no IDs have been recorded.
+// The caller must hold the lock.
+func (p PostIds) read() (map[string]map[string]string, error) {
+	ids := map[string]map[string]string{}
+	data, err := os.ReadFile(p.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return ids, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", p.File, err)
+	}
+	return ids, nil
+}
+
+// Write the file. The caller must hold the lock.
+func (p PostIds) write(ids map[string]map[string]string) error {
+	if err := os.MkdirAll(filepath.Dir(p.File), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomically(p.File, func(file io.Writer) error {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(ids)
+	})
+}
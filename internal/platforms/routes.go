@@ -0,0 +1,227 @@
+package platforms
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Route sends posts that match all of its conditions to its destinations.
+// Conditions that aren't set match every post, so a route with no conditions matches everything.
+type Route struct {
+	Name string
+	// Only match posts from one of these sources
+	Sources []string
+	// Only match posts with at least one of these labels (case-insensitive).
+	// Labels are the post's categories and tags, and its categories with the source's category prefix, such as "category::tech".
+	Labels     []string
+	Categories []string
+	Tags       []string
+	// If set, only match drafts (true) or only match posts that aren't drafts (false)
+	Draft        *bool
+	Destinations []string
+	// Stop ends the evaluation of routes after this one, so later routes can't add destinations
+	Stop bool
+}
+
+// Routes are evaluated in order for each post. The post is pushed to the destinations of every route that matches, until one with stop set matches.
+// If there are no routes, posts are pushed to every destination.
+type Routes []Route
+
+// RouteDecision records whether a route matched a post and why
+type RouteDecision struct {
+	Route   Route
+	Matched bool
+	// Skipped is true if an earlier route with stop set matched, so this route wasn't evaluated
+	Skipped bool
+	// Reasons has a line for each condition of the route, such as `has the tag "personal"`
+	Reasons []string
+}
+
+// Convert the routes list from the config file into Routes
+func RoutesFromInterface(r interface{}) (Routes, error) {
+	if r == nil {
+		return nil, nil
+	}
+	routeSlice, ok := r.([]interface{})
+	if !ok {
+		return nil, errors.New("routes is not a list")
+	}
+	routes := Routes{}
+	for i, rt := range routeSlice {
+		routeMap, ok := rt.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("route %d is not a table", i+1)
+		}
+		route := Route{}
+		route.Name, _ = routeMap["name"].(string)
+		if route.Name == "" {
+			route.Name = fmt.Sprintf("route %d", i+1)
+		}
+		var err error
+		for key, field := range map[string]*[]string{
+			"sources":      &route.Sources,
+			"labels":       &route.Labels,
+			"categories":   &route.Categories,
+			"tags":         &route.Tags,
+			"destinations": &route.Destinations,
+		} {
+			*field, err = stringSliceFromInterface(routeMap[key])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", route.Name, key, err)
+			}
+		}
+		if draft, ok := routeMap["draft"].(bool); ok {
+			route.Draft = &draft
+		} else if routeMap["draft"] != nil {
+			return nil, fmt.Errorf("%s: draft should be true or false", route.Name)
+		}
+		route.Stop, _ = routeMap["stop"].(bool)
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// Validate checks that every destination the routes send posts to is configured
+func (r Routes) Validate(destinationNames []string) error {
+	for _, route := range r {
+		for _, destination := range route.Destinations {
+			if !containsFold(destinationNames, destination) {
+				return fmt.Errorf("%s: destination %q isn't configured", route.Name, destination)
+			}
+		}
+	}
+	return nil
+}
+
+// Evaluate returns the names of the destinations the post should be pushed to, along with a decision for each route.
+// categoryPrefix is the category prefix of the post's source, if it has one, so labels such as "category::tech" can be matched.
+func (r Routes) Evaluate(data PostData, categoryPrefix string) ([]string, []RouteDecision) {
+	destinations := []string{}
+	decisions := []RouteDecision{}
+	stopped := false
+	for _, route := range r {
+		if stopped {
+			decisions = append(decisions, RouteDecision{Route: route, Skipped: true})
+			continue
+		}
+		decision := route.evaluate(data, categoryPrefix)
+		decisions = append(decisions, decision)
+		if !decision.Matched {
+			continue
+		}
+		for _, destination := range route.Destinations {
+			if !containsFold(destinations, destination) {
+				destinations = append(destinations, destination)
+			}
+		}
+		stopped = route.Stop
+	}
+	return destinations, decisions
+}
+
+// Filter returns the destinations in destinationSlice that the routes send the post to.
+// If there are no routes, destinationSlice is returned unchanged.
+func (r Routes) Filter(data PostData, categoryPrefix string, destinationSlice []Destination) []Destination {
+	if len(r) == 0 {
+		return destinationSlice
+	}
+	names, _ := r.Evaluate(data, categoryPrefix)
+	routed := []Destination{}
+	for _, destination := range destinationSlice {
+		if containsFold(names, destination.GetName()) {
+			routed = append(routed, destination)
+		}
+	}
+	return routed
+}
+
+// Check each of the route's conditions against the post
+func (route Route) evaluate(data PostData, categoryPrefix string) RouteDecision {
+	decision := RouteDecision{Route: route, Matched: true}
+	check := func(matched bool, reason string) {
+		if !matched {
+			decision.Matched = false
+			reason = "not " + reason
+		}
+		decision.Reasons = append(decision.Reasons, reason)
+	}
+	if len(route.Sources) > 0 {
+		check(containsFold(route.Sources, data.Source), fmt.Sprintf("from one of the sources %s", quoteList(route.Sources)))
+	}
+	if len(route.Labels) > 0 {
+		label, ok := firstFold(route.Labels, postLabels(data, categoryPrefix))
+		if ok {
+			check(true, fmt.Sprintf("has the label %q", label))
+		} else {
+			check(false, fmt.Sprintf("labeled with any of %s", quoteList(route.Labels)))
+		}
+	}
+	if len(route.Categories) > 0 {
+		category, ok := firstFold(route.Categories, data.Categories)
+		if ok {
+			check(true, fmt.Sprintf("has the category %q", category))
+		} else {
+			check(false, fmt.Sprintf("in any of the categories %s", quoteList(route.Categories)))
+		}
+	}
+	if len(route.Tags) > 0 {
+		tag, ok := firstFold(route.Tags, data.Tags)
+		if ok {
+			check(true, fmt.Sprintf("has the tag %q", tag))
+		} else {
+			check(false, fmt.Sprintf("tagged with any of %s", quoteList(route.Tags)))
+		}
+	}
+	if route.Draft != nil {
+		if *route.Draft {
+			check(data.Draft, "a draft")
+		} else {
+			check(!data.Draft, "published")
+		}
+	}
+	if len(decision.Reasons) == 0 {
+		decision.Reasons = append(decision.Reasons, "matches every post")
+	}
+	return decision
+}
+
+// Return the labels a post can be matched by: its categories, with and without the category prefix, and its tags
+func postLabels(data PostData, categoryPrefix string) []string {
+	labels := append([]string{}, data.Categories...)
+	if categoryPrefix != "" {
+		for _, category := range data.Categories {
+			labels = append(labels, categoryPrefix+category)
+		}
+	}
+	return append(labels, data.Tags...)
+}
+
+// Return the first item of values that's in wanted, ignoring case
+func firstFold(wanted []string, values []string) (string, bool) {
+	for _, value := range values {
+		if containsFold(wanted, value) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func quoteList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = fmt.Sprintf("%q", item)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// CategoryPrefix returns the category prefix of a source, such as "category::" for Blogger, or an empty string if it doesn't have one
+func CategoryPrefix(source Source) string {
+	switch s := source.(type) {
+	case *Blogger:
+		return s.CategoryPrefix
+	case *BloggerExport:
+		return s.CategoryPrefix
+	}
+	return ""
+}
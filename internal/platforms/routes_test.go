@@ -0,0 +1,124 @@
+package platforms
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRoutesEvaluate(t *testing.T) {
+	draft := true
+	published := false
+	routes := Routes{
+		{Name: "drafts", Draft: &draft, Destinations: []string{"preview"}, Stop: true},
+		{Name: "tech", Labels: []string{"category::Tech"}, Destinations: []string{"hashnode", "Mastodon"}},
+		{Name: "personal", Sources: []string{"site"}, Tags: []string{"personal"}, Destinations: []string{"mastodon", "bluesky"}},
+		{Name: "everything", Draft: &published, Destinations: []string{"archive"}},
+	}
+	tests := []struct {
+		name           string
+		routes         Routes
+		data           PostData
+		categoryPrefix string
+		want           []string
+		matched        []string
+		skipped        []string
+	}{
+		{
+			name:    "stop ends evaluation",
+			routes:  routes,
+			data:    PostData{Draft: true, Categories: []string{"tech"}},
+			want:    []string{"preview"},
+			matched: []string{"drafts"},
+			skipped: []string{"tech", "personal", "everything"},
+		},
+		{
+			name:           "labels use the category prefix and ignore case",
+			routes:         routes,
+			data:           PostData{Categories: []string{"tech"}},
+			categoryPrefix: "category::",
+			want:           []string{"hashnode", "Mastodon", "archive"},
+			matched:        []string{"tech", "everything"},
+		},
+		{
+			name:    "prefixed labels don't match without a category prefix",
+			routes:  routes,
+			data:    PostData{Categories: []string{"tech"}},
+			want:    []string{"archive"},
+			matched: []string{"everything"},
+		},
+		{
+			name:           "destinations aren't repeated",
+			routes:         routes,
+			data:           PostData{Source: "site", Categories: []string{"Tech"}, Tags: []string{"Personal"}},
+			categoryPrefix: "category::",
+			want:           []string{"hashnode", "Mastodon", "bluesky", "archive"},
+			matched:        []string{"tech", "personal", "everything"},
+		},
+		{
+			name:    "every condition has to match",
+			routes:  routes,
+			data:    PostData{Source: "blogger", Tags: []string{"personal"}},
+			want:    []string{"archive"},
+			matched: []string{"everything"},
+		},
+		{
+			name:    "a route with no conditions matches everything",
+			routes:  Routes{{Name: "all", Destinations: []string{"archive"}}},
+			data:    PostData{Draft: true},
+			want:    []string{"archive"},
+			matched: []string{"all"},
+		},
+		{
+			name:   "no routes",
+			routes: Routes{},
+			data:   PostData{Title: "Anything"},
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, decisions := tt.routes.Evaluate(tt.data, tt.categoryPrefix)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("destinations = %q, want %q", got, tt.want)
+			}
+			if len(decisions) != len(tt.routes) {
+				t.Fatalf("got %d decisions for %d routes", len(decisions), len(tt.routes))
+			}
+			matched, skipped := []string{}, []string{}
+			for _, decision := range decisions {
+				if decision.Matched {
+					matched = append(matched, decision.Route.Name)
+				}
+				if decision.Skipped {
+					skipped = append(skipped, decision.Route.Name)
+				} else if len(decision.Reasons) == 0 {
+					t.Errorf("route %s has no reasons", decision.Route.Name)
+				}
+			}
+			if tt.matched == nil {
+				tt.matched = []string{}
+			}
+			if tt.skipped == nil {
+				tt.skipped = []string{}
+			}
+			if !reflect.DeepEqual(matched, tt.matched) {
+				t.Errorf("matched routes = %q, want %q", matched, tt.matched)
+			}
+			if !reflect.DeepEqual(skipped, tt.skipped) {
+				t.Errorf("skipped routes = %q, want %q", skipped, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestRouteReasons(t *testing.T) {
+	route := Route{Name: "tech", Tags: []string{"go", "rust"}, Draft: new(bool)}
+	decision := route.evaluate(PostData{Tags: []string{"Rust"}, Draft: true}, "")
+	want := []string{`has the tag "Rust"`, "not published"}
+	if decision.Matched {
+		t.Error("route matched a draft")
+	}
+	if !reflect.DeepEqual(decision.Reasons, want) {
+		t.Errorf("reasons = %q, want %q", decision.Reasons, want)
+	}
+}
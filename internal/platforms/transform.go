@@ -0,0 +1,426 @@
+package platforms
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"plugin"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Transformer modifies post data after it's pulled from a source and before it's pushed to a destination
+type Transformer interface {
+	Transform(PostData) (PostData, error)
+}
+
+// Transforms is embedded in destinations to give them an ordered chain of transformers.
+// It is configured with the transforms key of a destination.
+type Transforms struct {
+	Transformers []Transformer
+}
+
+// TransformableDestination is a destination that has a chain of transformers to run before pushing
+type TransformableDestination interface {
+	Destination
+	ApplyTransforms(PostData) (PostData, error)
+}
+
+// Run each transformer in order, passing the output of one to the next
+func (t Transforms) ApplyTransforms(data PostData) (PostData, error) {
+	for i, transformer := range t.Transformers {
+		var err error
+		data, err = transformer.Transform(data)
+		if err != nil {
+			return PostData{}, fmt.Errorf("transform %d failed: %w", i+1, err)
+		}
+	}
+	return data, nil
+}
+
+// Convert the transforms key of a destination (interface{} due to how Viper works) to a Transforms struct
+func TransformsFromInterface(t interface{}) (Transforms, error) {
+	if t == nil {
+		return Transforms{}, nil
+	}
+	transformSlice, ok := t.([]interface{})
+	if !ok {
+		return Transforms{}, errors.New("transforms is not a list")
+	}
+	transforms := Transforms{}
+	for i, tr := range transformSlice {
+		transformMap, ok := tr.(map[string]interface{})
+		if !ok {
+			return Transforms{}, fmt.Errorf("transform %d is not a table", i+1)
+		}
+		transformer, err := CreateTransformer(transformMap)
+		if err != nil {
+			return Transforms{}, fmt.Errorf("transform %d: %w", i+1, err)
+		}
+		transforms.Transformers = append(transforms.Transformers, transformer)
+	}
+	return transforms, nil
+}
+
+// Create a Transformer from its configuration
+func CreateTransformer(transformMap map[string]interface{}) (Transformer, error) {
+	switch transformMap["type"] {
+	case "regex_replace":
+		pattern, ok := transformMap["pattern"].(string)
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("pattern is required for regex_replace")
+		}
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		replacement, _ := transformMap["replacement"].(string)
+		field, _ := transformMap["field"].(string)
+		if err := validateField(field); err != nil {
+			return nil, err
+		}
+		return RegexReplace{Regex: regex, Replacement: replacement, Field: field}, nil
+	case "strip_classes":
+		// If no classes are given, every class attribute is removed
+		classes, err := stringSliceFromInterface(transformMap["classes"])
+		if err != nil {
+			return nil, fmt.Errorf("classes: %w", err)
+		}
+		return StripClasses{Classes: classes}, nil
+	case "prepend", "append":
+		markdownTemplate, _ := transformMap["markdown"].(string)
+		htmlTemplate, _ := transformMap["html"].(string)
+		if markdownTemplate == "" && htmlTemplate == "" {
+			return nil, fmt.Errorf("markdown or html is required for %s", transformMap["type"])
+		}
+		insert := Insert{Append: transformMap["type"] == "append"}
+		var err error
+		if markdownTemplate != "" {
+			insert.Markdown, err = template.New("markdown").Parse(markdownTemplate)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if htmlTemplate != "" {
+			// html/template is used for the HTML variant so the post's fields are escaped, the same as with attribution
+			insert.Html, err = htmltemplate.New("html").Parse(htmlTemplate)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return insert, nil
+	case "heading_shift":
+		by, err := intFromInterface(transformMap["by"])
+		if err != nil {
+			return nil, fmt.Errorf("by: %w", err)
+		}
+		return HeadingShift{By: by}, nil
+	case "footnotes":
+		heading, ok := transformMap["heading"].(string)
+		if !ok {
+			heading = "Notes"
+		}
+		return Footnotes{Heading: heading}, nil
+	case "plugin":
+		path, ok := transformMap["path"].(string)
+		if !ok || path == "" {
+			return nil, fmt.Errorf("path is required for plugin")
+		}
+		return loadTransformerPlugin(path)
+	default:
+		return nil, fmt.Errorf("unknown transform type: %s", transformMap["type"])
+	}
+}
+
+// Field can be "markdown", "html", or "" for both
+func validateField(field string) error {
+	switch field {
+	case "", "markdown", "html":
+		return nil
+	default:
+		return fmt.Errorf("unknown field: %s", field)
+	}
+}
+
+// RegexReplace replaces every match of Regex in the post body with Replacement.
+// Replacement can reference capture groups, such as $1.
+type RegexReplace struct {
+	Regex       *regexp.Regexp
+	Replacement string
+	// Field is "markdown", "html", or "" for both
+	Field string
+}
+
+func (r RegexReplace) Transform(data PostData) (PostData, error) {
+	if r.Field == "" || r.Field == "markdown" {
+		data.Markdown = r.Regex.ReplaceAllString(data.Markdown, r.Replacement)
+	}
+	if r.Field == "" || r.Field == "html" {
+		data.Html = r.Regex.ReplaceAllString(data.Html, r.Replacement)
+	}
+	return data, nil
+}
+
+var classAttributeRegex = regexp.MustCompile(`\s+class\s*=\s*("[^"]*"|'[^']*')`)
+
+// StripClasses removes classes from HTML elements in the post body.
+// If Classes is empty, every class attribute is removed.
+type StripClasses struct {
+	Classes []string
+}
+
+func (s StripClasses) Transform(data PostData) (PostData, error) {
+	strip := func(content string) string {
+		return classAttributeRegex.ReplaceAllStringFunc(content, func(match string) string {
+			if len(s.Classes) == 0 {
+				return ""
+			}
+			quoted := classAttributeRegex.FindStringSubmatch(match)[1]
+			kept := []string{}
+			for _, class := range strings.Fields(quoted[1 : len(quoted)-1]) {
+				remove := false
+				for _, c := range s.Classes {
+					if class == c {
+						remove = true
+						break
+					}
+				}
+				if !remove {
+					kept = append(kept, class)
+				}
+			}
+			if len(kept) == 0 {
+				return ""
+			}
+			return fmt.Sprintf(` class="%s"`, strings.Join(kept, " "))
+		})
+	}
+	data.Html = strip(data.Html)
+	// Markdown can contain raw HTML as well
+	data.Markdown = strip(data.Markdown)
+	return data, nil
+}
+
+// Insert renders templates with the post data and adds them to the start or end of the post body
+type Insert struct {
+	Markdown *template.Template
+	Html     *htmltemplate.Template
+	Append   bool
+}
+
+func (i Insert) Transform(data PostData) (PostData, error) {
+	// Render both templates before modifying the data so they both see the original post
+	var markdown, html string
+	var err error
+	if i.Markdown != nil {
+		markdown, err = executeTemplate(i.Markdown, data)
+		if err != nil {
+			return PostData{}, err
+		}
+	}
+	if i.Html != nil {
+		var htmlBuf strings.Builder
+		if err := i.Html.Execute(&htmlBuf, data); err != nil {
+			return PostData{}, err
+		}
+		html = htmlBuf.String()
+	}
+	if i.Markdown != nil {
+		if i.Append {
+			data.Markdown = strings.TrimRight(data.Markdown, "\n") + "\n\n" + markdown
+		} else {
+			data.Markdown = markdown + "\n\n" + data.Markdown
+		}
+	}
+	if i.Html != nil {
+		if i.Append {
+			data.Html = data.Html + "\n" + html
+		} else {
+			data.Html = html + "\n" + data.Html
+		}
+	}
+	return data, nil
+}
+
+func executeTemplate(t *template.Template, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+var markdownHeadingRegex = regexp.MustCompile(`(?m)^(#{1,6})(\s)`)
+var htmlHeadingRegex = regexp.MustCompile(`(?i)<(/?)h([1-6])([\s>])`)
+
+// HeadingShift changes the level of every heading by By, clamping the result between 1 and 6.
+// For example, a By of 1 turns h1 into h2 for platforms that use h1 for the post title.
+type HeadingShift struct {
+	By int
+}
+
+func (h HeadingShift) shift(level int) int {
+	level += h.By
+	if level < 1 {
+		return 1
+	}
+	if level > 6 {
+		return 6
+	}
+	return level
+}
+
+func (h HeadingShift) Transform(data PostData) (PostData, error) {
+	data.Markdown = replaceOutsideCodeFences(data.Markdown, func(content string) string {
+		return markdownHeadingRegex.ReplaceAllStringFunc(content, func(match string) string {
+			groups := markdownHeadingRegex.FindStringSubmatch(match)
+			return strings.Repeat("#", h.shift(len(groups[1]))) + groups[2]
+		})
+	})
+	data.Html = htmlHeadingRegex.ReplaceAllStringFunc(data.Html, func(match string) string {
+		groups := htmlHeadingRegex.FindStringSubmatch(match)
+		level, _ := strconv.Atoi(groups[2])
+		return fmt.Sprintf("<%sh%d%s", groups[1], h.shift(level), groups[3])
+	})
+	return data, nil
+}
+
+var footnoteDefinitionRegex = regexp.MustCompile(`(?m)^\[\^([^\]]+)\]:[ \t]*(.*)$`)
+var footnoteReferenceRegex = regexp.MustCompile(`\[\^([^\]]+)\]`)
+
+// Footnotes converts Markdown footnotes ([^1] and [^1]: text) into numbered superscripts and a list of notes at the end of the post.
+// This is useful for platforms whose Markdown doesn't support footnotes.
+type Footnotes struct {
+	// Heading is placed above the list of notes. If empty, no heading is added.
+	Heading string
+}
+
+func (f Footnotes) Transform(data PostData) (PostData, error) {
+	definitions := map[string]string{}
+	body := replaceOutsideCodeFences(data.Markdown, func(content string) string {
+		return footnoteDefinitionRegex.ReplaceAllStringFunc(content, func(match string) string {
+			groups := footnoteDefinitionRegex.FindStringSubmatch(match)
+			definitions[groups[1]] = strings.TrimSpace(groups[2])
+			return ""
+		})
+	})
+	if len(definitions) == 0 {
+		return data, nil
+	}
+	// Number the footnotes in the order they are referenced
+	numbers := map[string]int{}
+	order := []string{}
+	body = replaceOutsideCodeFences(body, func(content string) string {
+		return footnoteReferenceRegex.ReplaceAllStringFunc(content, func(match string) string {
+			id := footnoteReferenceRegex.FindStringSubmatch(match)[1]
+			if _, ok := definitions[id]; !ok {
+				return match
+			}
+			if _, ok := numbers[id]; !ok {
+				order = append(order, id)
+				numbers[id] = len(order)
+			}
+			return fmt.Sprintf("<sup>%d</sup>", numbers[id])
+		})
+	})
+	notes := strings.TrimRight(body, "\n") + "\n\n"
+	if f.Heading != "" {
+		notes += "## " + f.Heading + "\n\n"
+	}
+	for _, id := range order {
+		notes += fmt.Sprintf("%d. %s\n", numbers[id], definitions[id])
+	}
+	data.Markdown = notes
+	return data, nil
+}
+
+// Call replace on every part of the Markdown that isn't inside a fenced code block
+func replaceOutsideCodeFences(markdown string, replace func(string) string) string {
+	lines := strings.SplitAfter(markdown, "\n")
+	var result, chunk strings.Builder
+	inFence := false
+	fence := ""
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !inFence && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")) {
+			result.WriteString(replace(chunk.String()))
+			chunk.Reset()
+			inFence = true
+			fence = trimmed[:3]
+			result.WriteString(line)
+			continue
+		}
+		if inFence {
+			result.WriteString(line)
+			if strings.HasPrefix(trimmed, fence) {
+				inFence = false
+			}
+			continue
+		}
+		chunk.WriteString(line)
+	}
+	result.WriteString(replace(chunk.String()))
+	return result.String()
+}
+
+// Load a Go plugin (built with go build -buildmode=plugin) that exports a variable named Transformer implementing the Transformer interface.
+func loadTransformerPlugin(path string) (Transformer, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plugin %s: %w", path, err)
+	}
+	symbol, err := p.Lookup("Transformer")
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s does not export Transformer: %w", path, err)
+	}
+	// Lookup returns a pointer to exported variables
+	if transformer, ok := symbol.(*Transformer); ok {
+		return *transformer, nil
+	}
+	if transformer, ok := symbol.(Transformer); ok {
+		return transformer, nil
+	}
+	return nil, fmt.Errorf("plugin %s exports Transformer but it does not implement the Transformer interface", path)
+}
+
+// Convert a list (interface{} due to how Viper works) to a slice of strings. nil results in an empty slice.
+func stringSliceFromInterface(i interface{}) ([]string, error) {
+	if i == nil {
+		return []string{}, nil
+	}
+	switch list := i.(type) {
+	case []string:
+		return list, nil
+	case []interface{}:
+		stringSlice := []string{}
+		for _, item := range list {
+			str, ok := item.(string)
+			if !ok {
+				return nil, errors.New("one of the items is not a string")
+			}
+			stringSlice = append(stringSlice, str)
+		}
+		return stringSlice, nil
+	default:
+		return nil, errors.New("not a list")
+	}
+}
+
+// Convert a number (interface{} due to how Viper works) to an int. Depending on the config format, numbers can be int64 or float64.
+func intFromInterface(i interface{}) (int, error) {
+	switch n := i.(type) {
+	case int:
+		return n, nil
+	case int64:
+		return int(n), nil
+	case float64:
+		return int(n), nil
+	case nil:
+		return 0, errors.New("is required")
+	default:
+		return 0, errors.New("not a number")
+	}
+}
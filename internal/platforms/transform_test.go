@@ -0,0 +1,55 @@
+package platforms
+
+import "testing"
+
+func TestInsertTransform(t *testing.T) {
+	data := PostData{
+		Title:        `Fish & "Chips" <3`,
+		CanonicalUrl: "https://example.com/fish?a=1&b=2",
+		Html:         "<p>Body</p>",
+		Markdown:     "Body\n",
+	}
+	tests := []struct {
+		name         string
+		transform    map[string]interface{}
+		wantHtml     string
+		wantMarkdown string
+	}{
+		{
+			name:         "html is escaped but markdown isn't",
+			transform:    map[string]interface{}{"type": "prepend", "html": "<h2>{{.Title}}</h2>", "markdown": "## {{.Title}}"},
+			wantHtml:     "<h2>Fish &amp; &#34;Chips&#34; &lt;3</h2>\n<p>Body</p>",
+			wantMarkdown: "## Fish & \"Chips\" <3\n\nBody\n",
+		},
+		{
+			name:         "urls in attributes",
+			transform:    map[string]interface{}{"type": "append", "html": `<a href="{{.CanonicalUrl}}">{{.Title}}</a>`},
+			wantHtml:     "<p>Body</p>\n<a href=\"https://example.com/fish?a=1&amp;b=2\">Fish &amp; &#34;Chips&#34; &lt;3</a>",
+			wantMarkdown: "Body\n",
+		},
+		{
+			name:         "markdown only",
+			transform:    map[string]interface{}{"type": "append", "markdown": "Read it at {{.CanonicalUrl}}"},
+			wantHtml:     "<p>Body</p>",
+			wantMarkdown: "Body\n\nRead it at https://example.com/fish?a=1&b=2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transforms, err := TransformsFromInterface([]interface{}{tt.transform})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := transforms.ApplyTransforms(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Html != tt.wantHtml {
+				t.Errorf("Html = %q, want %q", got.Html, tt.wantHtml)
+			}
+			if got.Markdown != tt.wantMarkdown {
+				t.Errorf("Markdown = %q, want %q", got.Markdown, tt.wantMarkdown)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package platforms
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/go-resty/resty/v2"
+)
+
+// The header the HMAC-SHA256 signature of the body is sent in, as "sha256=" followed by the hex digest
+const WebhookSignatureHeader = "X-Cross-Blogger-Signature"
+
+// Webhook sends each pushed post to a URL, such as to trigger a site build or a chat notification
+type Webhook struct {
+	Name string
+	Url  string
+	// Headers are added to every request
+	Headers map[string]string
+	// If BodyTemplate is set, it's executed with a WebhookPayload to make the body instead of sending the payload as JSON
+	BodyTemplate *template.Template
+	ContentType  string
+	// Retries is how many times a failed request is retried
+	Retries int
+	// PostIds remembers which posts were sent, so later pushes are sent as updates
+	PostIds PostIds
+	Transforms
+	PushHooks
+}
+
+func (w Webhook) GetName() string { return w.Name }
+func (w Webhook) GetType() string { return "webhook" }
+
+// WebhookPayload is the body of each request
+type WebhookPayload struct {
+	// Event is "publish" the first time a post is sent, "update" after that, and "delete" when it's deleted
+	Event       string    `json:"event"`
+	Source      string    `json:"source"`
+	Destination string    `json:"destination"`
+	Timestamp   time.Time `json:"timestamp"`
+	Post        PostData  `json:"post"`
+}
+
+// Push sends the post to the URL
+func (w Webhook) Push(data PostData, options PushPullOptions) error {
+	deliveryId, err := w.PostIds.Get(w.Name, data)
+	if err != nil {
+		return err
+	}
+	event := "publish"
+	if deliveryId != "" {
+		event = "update"
+	}
+	deliveryId, err = w.send(event, data, options)
+	if err != nil {
+		return err
+	}
+	return w.PostIds.Set(w.Name, data, deliveryId)
+}
+
+// Delete tells the URL that the post was deleted
+func (w Webhook) Delete(data PostData, options PushPullOptions) error {
+	if _, err := w.send("delete", data, options); err != nil {
+		return err
+	}
+	return w.PostIds.Delete(w.Name, data)
+}
+
+// Send an event and return the ID of the delivery
+func (w Webhook) send(event string, data PostData, options PushPullOptions) (string, error) {
+	payload := WebhookPayload{
+		Event:       event,
+		Source:      data.Source,
+		Destination: w.Name,
+		Timestamp:   time.Now().UTC(),
+		Post:        data,
+	}
+	var body []byte
+	if w.BodyTemplate != nil {
+		rendered, err := executeTemplate(w.BodyTemplate, payload)
+		if err != nil {
+			return "", fmt.Errorf("failed to render webhook body: %w", err)
+		}
+		body = []byte(rendered)
+	} else {
+		var err error
+		body, err = json.Marshal(payload)
+		if err != nil {
+			return "", err
+		}
+	}
+	deliveryId, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+
+	request := resty.New().
+		SetRetryCount(w.Retries).
+		SetRetryWaitTime(time.Second).
+		SetRetryMaxWaitTime(30*time.Second).
+		// Retry server errors and rate limiting as well as connection errors
+		AddRetryCondition(func(resp *resty.Response, err error) bool {
+			return err != nil || resp.StatusCode() >= 500 || resp.StatusCode() == 429
+		}).
+		R().
+		SetHeaders(w.Headers).
+		SetHeader("Content-Type", w.ContentType).
+		SetHeader("User-Agent", "cross-blogger").
+		SetHeader("X-Cross-Blogger-Event", event).
+		SetHeader("X-Cross-Blogger-Delivery", deliveryId).
+		SetBody(body)
+	// The signature lets the receiver check that the request came from us and wasn't changed
+	if options.WebhookSecret != "" {
+		request.SetHeader(WebhookSignatureHeader, "sha256="+SignPayload(body, options.WebhookSecret))
+	}
+	resp, err := request.Post(w.Url)
+	if err != nil {
+		return "", err
+	}
+	if !resp.IsSuccess() {
+		return "", fmt.Errorf("webhook returned %s: %s", resp.Status(), resp.String())
+	}
+	log.Info("Sent webhook", "destination", w.Name, "event", event, "title", data.Title, "status", resp.StatusCode())
+	return deliveryId, nil
+}
+
+// Functions available in body templates. json encodes a value as JSON, such as to put a title in a JSON string safely.
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	},
+}
+
+// SignPayload returns the hex-encoded HMAC-SHA256 of the body with the secret
+func SignPayload(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Return n random bytes as hex
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
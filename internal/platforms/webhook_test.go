@@ -0,0 +1,33 @@
+package platforms
+
+import "testing"
+
+func TestSignPayload(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		secret string
+		want   string
+	}{
+		{
+			// Test case 2 from RFC 4231
+			name:   "rfc 4231",
+			body:   "what do ya want for nothing?",
+			secret: "Jefe",
+			want:   "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+		},
+		{
+			name:   "empty body and secret",
+			body:   "",
+			secret: "",
+			want:   "b613679a0814d9ec772f95d778c35fc5ff1697c493715653c6c712144292c5ad",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SignPayload([]byte(tt.body), tt.secret); got != tt.want {
+				t.Errorf("SignPayload(%q, %q) = %s, want %s", tt.body, tt.secret, got, tt.want)
+			}
+		})
+	}
+}
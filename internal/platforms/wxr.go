@@ -0,0 +1,687 @@
+package platforms
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gosimple/slug"
+	"github.com/slashtechno/cross-blogger/pkg/utils"
+)
+
+// Wxr reads and writes WordPress eXtended RSS (WXR) files, the format of WordPress's exporter and importer.
+// As a source, it reads posts from an export without network access.
+// As a destination, it maintains a file that can be imported into any WordPress site with Tools > Import > WordPress.
+type Wxr struct {
+	Name string
+	// File is the path to the WXR file
+	File string
+	// BlogUrl is written to the file as the URL of the site. As a source, it's only used if the file doesn't have one.
+	BlogUrl string
+	// Author is the login used for posts without an author when writing. It defaults to "admin".
+	Author    string
+	Overwrite bool
+	// Embeds are converted to shortcodes when reading
+	Embeds []EmbedRule
+	Transforms
+	PushHooks
+}
+
+func (w Wxr) GetName() string { return w.Name }
+func (w Wxr) GetType() string { return "wxr" }
+
+// The namespaces used when writing. When reading, elements are matched by their local name so any WXR version works.
+const (
+	wxrContentNamespace = "http://purl.org/rss/1.0/modules/content/"
+	wxrExcerptNamespace = "http://wordpress.org/export/1.2/excerpt/"
+	// WordPress's format for dates in WXR files
+	wxrDateLayout = "2006-01-02 15:04:05"
+)
+
+type wxrRss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Channel wxrChannel `xml:"channel"`
+}
+
+type wxrChannel struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	Description string        `xml:"description"`
+	Language    string        `xml:"language"`
+	BaseSiteUrl string        `xml:"base_site_url"`
+	BaseBlogUrl string        `xml:"base_blog_url"`
+	Authors     []wxrAuthor   `xml:"author"`
+	Categories  []wxrCategory `xml:"category"`
+	Tags        []wxrTag      `xml:"tag"`
+	Items       []wxrItem     `xml:"item"`
+}
+
+type wxrAuthor struct {
+	Id          string `xml:"author_id"`
+	Login       string `xml:"author_login"`
+	Email       string `xml:"author_email"`
+	DisplayName string `xml:"author_display_name"`
+}
+
+type wxrCategory struct {
+	Nicename string `xml:"category_nicename"`
+	Parent   string `xml:"category_parent"`
+	Name     string `xml:"cat_name"`
+}
+
+type wxrTag struct {
+	Slug string `xml:"tag_slug"`
+	Name string `xml:"tag_name"`
+}
+
+type wxrItem struct {
+	Title   string `xml:"title"`
+	Link    string `xml:"link"`
+	PubDate string `xml:"pubDate"`
+	Creator string `xml:"creator"`
+	Guid    string `xml:"guid"`
+	// content:encoded and excerpt:encoded share a local name, so they're told apart by namespace
+	Encoded         []wxrEncoded      `xml:"encoded"`
+	PostId          string            `xml:"post_id"`
+	PostDate        string            `xml:"post_date"`
+	PostDateGmt     string            `xml:"post_date_gmt"`
+	PostModifiedGmt string            `xml:"post_modified_gmt"`
+	PostName        string            `xml:"post_name"`
+	Status          string            `xml:"status"`
+	PostParent      string            `xml:"post_parent"`
+	PostType        string            `xml:"post_type"`
+	AttachmentUrl   string            `xml:"attachment_url"`
+	Categories      []wxrItemCategory `xml:"category"`
+	Comments        []wxrComment      `xml:"comment"`
+}
+
+type wxrEncoded struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+type wxrItemCategory struct {
+	// Domain is "category" or "post_tag"
+	Domain   string `xml:"domain,attr"`
+	Nicename string `xml:"nicename,attr"`
+	Name     string `xml:",chardata"`
+}
+
+type wxrComment struct {
+	Id          string `xml:"comment_id"`
+	Author      string `xml:"comment_author"`
+	AuthorEmail string `xml:"comment_author_email"`
+	AuthorUrl   string `xml:"comment_author_url"`
+	Date        string `xml:"comment_date"`
+	DateGmt     string `xml:"comment_date_gmt"`
+	Content     string `xml:"comment_content"`
+	Approved    string `xml:"comment_approved"`
+	Parent      string `xml:"comment_parent"`
+}
+
+// Return the value of the encoded element in the namespace containing space
+func (i wxrItem) encoded(space string) string {
+	for _, encoded := range i.Encoded {
+		if strings.Contains(encoded.XMLName.Space, space) {
+			return encoded.Value
+		}
+	}
+	return ""
+}
+
+func (i wxrItem) Content() string { return i.encoded("/content/") }
+func (i wxrItem) Excerpt() string { return i.encoded("/excerpt/") }
+
+// Return when the item was published, preferring the GMT date
+func (i wxrItem) date() time.Time {
+	for _, value := range []string{i.PostDateGmt, i.PostDate} {
+		// Drafts have a GMT date of all zeroes
+		if value == "" || strings.HasPrefix(value, "0000") {
+			continue
+		}
+		if date, err := time.Parse(wxrDateLayout, value); err == nil {
+			return date
+		}
+	}
+	date, _ := time.Parse(time.RFC1123Z, i.PubDate)
+	return date
+}
+
+// Return the status of the item as "live", "draft", or "scheduled".
+// Pending and private posts aren't public, so they're treated as drafts.
+func (i wxrItem) listStatus() string {
+	switch i.Status {
+	case "publish":
+		return "live"
+	case "future":
+		return "scheduled"
+	default:
+		return "draft"
+	}
+}
+
+// Read the WXR file
+func (w Wxr) read() (*wxrRss, error) {
+	file, err := os.Open(w.File)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var rss wxrRss
+	if err := xml.NewDecoder(file).Decode(&rss); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", w.File, err)
+	}
+	return &rss, nil
+}
+
+// Return the posts in the file (oldest first), leaving out attachments, pages, and trashed posts
+func (c wxrChannel) posts() []wxrItem {
+	posts := []wxrItem{}
+	for _, item := range c.Items {
+		if item.PostType != "post" || item.Status == "trash" || item.Status == "auto-draft" {
+			continue
+		}
+		posts = append(posts, item)
+	}
+	sort.SliceStable(posts, func(i, j int) bool { return posts[i].date().Before(posts[j].date()) })
+	return posts
+}
+
+// Match the start of block-level HTML, which WordPress doesn't wrap in paragraphs
+var wxrBlockRegex = regexp.MustCompile(`(?i)^<(?:p|div|h[1-6]|ul|ol|li|blockquote|pre|table|figure|hr|iframe|script|!--)[\s>/]`)
+
+// Match the blank lines between paragraphs
+var wxrParagraphRegex = regexp.MustCompile(`\n\s*\n`)
+
+// WordPress's classic editor stores paragraphs as blank lines rather than <p> elements.
+// Wrap them in paragraphs the same way WordPress does when displaying the post, if the content doesn't already have any.
+func wordpressAutoParagraph(content string) string {
+	if strings.Contains(content, "<p>") || strings.Contains(content, "<p ") {
+		return content
+	}
+	paragraphs := wxrParagraphRegex.Split(strings.ReplaceAll(content, "\r\n", "\n"), -1)
+	var result strings.Builder
+	for _, paragraph := range paragraphs {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		if wxrBlockRegex.MatchString(paragraph) {
+			result.WriteString(paragraph + "\n")
+			continue
+		}
+		result.WriteString("<p>" + strings.ReplaceAll(paragraph, "\n", "<br />\n") + "</p>\n")
+	}
+	return result.String()
+}
+
+// Convert a post in the file into PostData
+func (w Wxr) postData(channel wxrChannel, item wxrItem) (PostData, error) {
+	html := wordpressAutoParagraph(item.Content())
+	markdown, err := HtmlToMarkdown(html, w.Embeds)
+	if err != nil {
+		return PostData{}, err
+	}
+	data := PostData{
+		Title:        item.Title,
+		Html:         html,
+		Markdown:     markdown,
+		Description:  strings.TrimSpace(item.Excerpt()),
+		CanonicalUrl: item.Link,
+		Draft:        item.listStatus() == "draft",
+		Categories:   []string{},
+		Tags:         []string{},
+	}
+	if !data.Draft {
+		data.Date = item.date()
+	}
+	if modified, err := time.Parse(wxrDateLayout, item.PostModifiedGmt); err == nil {
+		data.DateUpdated = modified
+	}
+	for _, category := range item.Categories {
+		switch category.Domain {
+		case "category":
+			data.Categories = append(data.Categories, category.Name)
+		case "post_tag":
+			data.Tags = append(data.Tags, category.Name)
+		}
+	}
+	// Use the author's display name if the file has it
+	data.Author = item.Creator
+	for _, author := range channel.Authors {
+		if author.Login == item.Creator && author.DisplayName != "" {
+			data.Author = author.DisplayName
+		}
+	}
+	for _, comment := range item.Comments {
+		// Leave out spam and comments waiting for moderation
+		if comment.Approved != "1" {
+			continue
+		}
+		date, _ := time.Parse(wxrDateLayout, comment.DateGmt)
+		data.Comments = append(data.Comments, Comment{
+			Author:    comment.Author,
+			AuthorUrl: comment.AuthorUrl,
+			Date:      date,
+			Html:      wordpressAutoParagraph(comment.Content),
+		})
+	}
+	for _, attachment := range channel.Items {
+		if attachment.PostType == "attachment" && attachment.PostParent == item.PostId && attachment.AttachmentUrl != "" {
+			data.Attachments = append(data.Attachments, Attachment{Url: attachment.AttachmentUrl, Title: attachment.Title})
+		}
+	}
+	return data, nil
+}
+
+// Pull a post from the file. options.PostUrl can be the post's URL, its slug (post_name), or its ID.
+func (w Wxr) Pull(options PushPullOptions) (PostData, error) {
+	rss, err := w.read()
+	if err != nil {
+		return PostData{}, err
+	}
+	specifier := options.PostUrl
+	for _, item := range rss.Channel.posts() {
+		if item.PostId == specifier || item.PostName == specifier || item.Link == specifier || item.Guid == specifier {
+			return w.postData(rss.Channel, item)
+		}
+	}
+	return PostData{}, fmt.Errorf("post not found in %s: %s", w.File, specifier)
+}
+
+// List every post in the file that matches the filter, oldest first
+func (w Wxr) ListPosts(options PushPullOptions, filter PostFilter) ([]ListedPost, error) {
+	rss, err := w.read()
+	if err != nil {
+		return nil, err
+	}
+	listed := []ListedPost{}
+	for _, item := range rss.Channel.posts() {
+		post := ListedPost{
+			Id:     item.PostId,
+			Title:  item.Title,
+			Date:   item.date(),
+			Status: item.listStatus(),
+			raw:    item,
+		}
+		for _, category := range item.Categories {
+			post.Labels = append(post.Labels, category.Name)
+		}
+		if filter.Matches(post) {
+			listed = append(listed, post)
+		}
+	}
+	return listed, nil
+}
+
+// Pull a post returned by ListPosts
+func (w Wxr) PullListed(post ListedPost, options PushPullOptions) (PostData, error) {
+	item, ok := post.raw.(wxrItem)
+	if !ok {
+		return PostData{}, fmt.Errorf("post %s was not listed from a WXR file", post.Id)
+	}
+	rss, err := w.read()
+	if err != nil {
+		return PostData{}, err
+	}
+	return w.postData(rss.Channel, item)
+}
+
+// Build an index of every published post in the file so links between posts can be rewritten
+func (w Wxr) BuildLinkIndex(options PushPullOptions) (LinkIndex, error) {
+	rss, err := w.read()
+	if err != nil {
+		return LinkIndex{}, err
+	}
+	blogUrl := rss.Channel.Link
+	if blogUrl == "" {
+		blogUrl = w.BlogUrl
+	}
+	index := LinkIndex{
+		BlogUrl: blogUrl,
+		Slugs:   map[string]string{},
+	}
+	for _, item := range rss.Channel.posts() {
+		if item.Status != "publish" || item.Link == "" {
+			continue
+		}
+		key, err := linkIndexKey(item.Link)
+		if err != nil {
+			return LinkIndex{}, err
+		}
+		// Use the same slug as Markdown.Push so the link points at the right file
+		index.Slugs[key] = slug.Make(item.Title)
+	}
+	return index, nil
+}
+
+// Pushing rewrites the whole file, so only one post is pushed at a time
+var wxrWriteLock sync.Mutex
+
+// Push the post to the WXR file, creating the file if it doesn't exist.
+// Posts are matched by slug, so pushing a post with the same title replaces it if overwrite is enabled.
+func (w Wxr) Push(data PostData, options PushPullOptions) error {
+	wxrWriteLock.Lock()
+	defer wxrWriteLock.Unlock()
+	rss, err := w.read()
+	if errors.Is(err, os.ErrNotExist) {
+		rss = &wxrRss{Channel: wxrChannel{Link: w.BlogUrl}}
+	} else if err != nil {
+		return err
+	}
+	channel := &rss.Channel
+	postSlug := slug.Make(data.Title)
+
+	// Find the existing post (if any) and the highest ID so a new ID can be picked
+	maxId := 0
+	existing := -1
+	for i, item := range channel.Items {
+		if id, err := strconv.Atoi(item.PostId); err == nil && id > maxId {
+			maxId = id
+		}
+		if item.PostType == "post" && item.PostName == postSlug {
+			existing = i
+		}
+	}
+	nextId := func() string {
+		maxId++
+		return strconv.Itoa(maxId)
+	}
+	postId := ""
+	if existing != -1 {
+		if !w.Overwrite {
+			return fmt.Errorf("post already exists in %s and overwrite is false: %s", w.File, postSlug)
+		}
+		log.Info("Replacing post as overwrite is true", "file", w.File, "slug", postSlug)
+		postId = channel.Items[existing].PostId
+		// Remove the post and its attachments; they're added again below
+		items := []wxrItem{}
+		for i, item := range channel.Items {
+			if i == existing || (item.PostType == "attachment" && item.PostParent == postId) {
+				continue
+			}
+			items = append(items, item)
+		}
+		channel.Items = items
+	} else {
+		postId = nextId()
+	}
+
+	item := w.itemFromPostData(data, postId, postSlug)
+	channel.Items = append(channel.Items, item)
+	for _, attachment := range data.Attachments {
+		channel.Items = append(channel.Items, wxrItem{
+			Title:         attachment.Title,
+			Link:          attachment.Url,
+			Guid:          attachment.Url,
+			PubDate:       item.PubDate,
+			Creator:       item.Creator,
+			PostId:        nextId(),
+			PostDate:      item.PostDate,
+			PostDateGmt:   item.PostDateGmt,
+			PostName:      slug.Make(utils.DefaultString(attachment.Title, filepath.Base(attachment.Url))),
+			Status:        "inherit",
+			PostParent:    postId,
+			PostType:      "attachment",
+			AttachmentUrl: attachment.Url,
+		})
+	}
+	channel.updateTerms()
+	channel.updateAuthors(data.Author, item.Creator)
+	return w.write(rss)
+}
+
+// Convert PostData into a WXR post
+func (w Wxr) itemFromPostData(data PostData, postId string, postSlug string) wxrItem {
+	date := data.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	date = date.UTC()
+	status := "publish"
+	if data.Draft {
+		status = "draft"
+	} else if date.After(time.Now()) {
+		status = "future"
+	}
+	creator := slug.Make(data.Author)
+	if creator == "" {
+		creator = utils.DefaultString(w.Author, "admin")
+	}
+	item := wxrItem{
+		Title:       data.Title,
+		Link:        data.CanonicalUrl,
+		PubDate:     date.Format(time.RFC1123Z),
+		Creator:     creator,
+		Guid:        data.CanonicalUrl,
+		PostId:      postId,
+		PostDate:    date.Format(wxrDateLayout),
+		PostDateGmt: date.Format(wxrDateLayout),
+		PostName:    postSlug,
+		Status:      status,
+		PostParent:  "0",
+		PostType:    "post",
+		Encoded: []wxrEncoded{
+			{XMLName: xml.Name{Space: wxrContentNamespace, Local: "encoded"}, Value: data.Html},
+			{XMLName: xml.Name{Space: wxrExcerptNamespace, Local: "encoded"}, Value: data.Description},
+		},
+	}
+	if item.Guid == "" {
+		item.Guid = strings.TrimSuffix(w.BlogUrl, "/") + "/?p=" + postId
+	}
+	// Drafts don't have a publish date in WordPress
+	if data.Draft {
+		item.PostDateGmt = "0000-00-00 00:00:00"
+	}
+	if !data.DateUpdated.IsZero() {
+		item.PostModifiedGmt = data.DateUpdated.UTC().Format(wxrDateLayout)
+	}
+	for _, category := range data.Categories {
+		item.Categories = append(item.Categories, wxrItemCategory{Domain: "category", Nicename: slug.Make(category), Name: category})
+	}
+	for _, tag := range data.Tags {
+		item.Categories = append(item.Categories, wxrItemCategory{Domain: "post_tag", Nicename: slug.Make(tag), Name: tag})
+	}
+	for i, comment := range data.Comments {
+		item.Comments = append(item.Comments, wxrComment{
+			Id:        strconv.Itoa(i + 1),
+			Author:    comment.Author,
+			AuthorUrl: comment.AuthorUrl,
+			Date:      comment.Date.UTC().Format(wxrDateLayout),
+			DateGmt:   comment.Date.UTC().Format(wxrDateLayout),
+			Content:   comment.Html,
+			Approved:  "1",
+			Parent:    "0",
+		})
+	}
+	return item
+}
+
+// Rebuild the list of categories and tags at the top of the file from the posts
+func (c *wxrChannel) updateTerms() {
+	categories := map[string]wxrCategory{}
+	tags := map[string]wxrTag{}
+	for _, item := range c.Items {
+		for _, term := range item.Categories {
+			switch term.Domain {
+			case "category":
+				categories[term.Nicename] = wxrCategory{Nicename: term.Nicename, Name: term.Name}
+			case "post_tag":
+				tags[term.Nicename] = wxrTag{Slug: term.Nicename, Name: term.Name}
+			}
+		}
+	}
+	c.Categories = []wxrCategory{}
+	for _, category := range categories {
+		c.Categories = append(c.Categories, category)
+	}
+	sort.Slice(c.Categories, func(i, j int) bool { return c.Categories[i].Nicename < c.Categories[j].Nicename })
+	c.Tags = []wxrTag{}
+	for _, tag := range tags {
+		c.Tags = append(c.Tags, tag)
+	}
+	sort.Slice(c.Tags, func(i, j int) bool { return c.Tags[i].Slug < c.Tags[j].Slug })
+}
+
+// Add the post's author to the list of authors at the top of the file if they aren't already in it
+func (c *wxrChannel) updateAuthors(displayName string, login string) {
+	maxId := 0
+	for _, author := range c.Authors {
+		if author.Login == login {
+			return
+		}
+		if id, err := strconv.Atoi(author.Id); err == nil && id > maxId {
+			maxId = id
+		}
+	}
+	c.Authors = append(c.Authors, wxrAuthor{
+		Id:          strconv.Itoa(maxId + 1),
+		Login:       login,
+		DisplayName: utils.DefaultString(displayName, login),
+	})
+}
+
+// Write the file, replacing it once it's been fully written
+func (w Wxr) write(rss *wxrRss) error {
+	if err := os.MkdirAll(filepath.Dir(w.File), 0755); err != nil {
+		return err
+	}
+	if rss.Channel.BaseSiteUrl == "" {
+		rss.Channel.BaseSiteUrl = utils.DefaultString(w.BlogUrl, rss.Channel.Link)
+	}
+	if rss.Channel.BaseBlogUrl == "" {
+		rss.Channel.BaseBlogUrl = rss.Channel.BaseSiteUrl
+	}
+	return writeFileAtomically(w.File, func(file io.Writer) error {
+		return wxrTemplate.Execute(file, rss.Channel)
+	})
+}
+
+// Write a file by writing to a temporary file and renaming it, so an interruption can't leave a partial file behind
+func writeFileAtomically(path string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Escape text for XML
+func wxrEscape(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
+
+// Wrap text in a CDATA section, splitting any "]]>" in the text so it doesn't end the section early
+func wxrCdata(value string) string {
+	return "<![CDATA[" + strings.ReplaceAll(value, "]]>", "]]]]><![CDATA[>") + "]]>"
+}
+
+// The file is written with a template since encoding/xml can't write namespace prefixes the way WordPress expects them
+var wxrTemplate = template.Must(template.New("wxr").Funcs(template.FuncMap{"xml": wxrEscape, "cdata": wxrCdata}).Parse(`<?xml version="1.0" encoding="UTF-8" ?>
+<!-- This is a WordPress eXtended RSS file generated by cross-blogger. Import it with Tools > Import > WordPress. -->
+<rss version="2.0"
+	xmlns:excerpt="` + wxrExcerptNamespace + `"
+	xmlns:content="` + wxrContentNamespace + `"
+	xmlns:wfw="http://wellformedweb.org/CommentAPI/"
+	xmlns:dc="http://purl.org/dc/elements/1.1/"
+	xmlns:wp="http://wordpress.org/export/1.2/"
+>
+<channel>
+	<title>{{xml .Title}}</title>
+	<link>{{xml .Link}}</link>
+	<description>{{xml .Description}}</description>
+	<language>{{xml .Language}}</language>
+	<wp:wxr_version>1.2</wp:wxr_version>
+	<wp:base_site_url>{{xml .BaseSiteUrl}}</wp:base_site_url>
+	<wp:base_blog_url>{{xml .BaseBlogUrl}}</wp:base_blog_url>
+{{- range .Authors}}
+	<wp:author><wp:author_id>{{xml .Id}}</wp:author_id><wp:author_login>{{cdata .Login}}</wp:author_login><wp:author_email>{{cdata .Email}}</wp:author_email><wp:author_display_name>{{cdata .DisplayName}}</wp:author_display_name><wp:author_first_name><![CDATA[]]></wp:author_first_name><wp:author_last_name><![CDATA[]]></wp:author_last_name></wp:author>
+{{- end}}
+{{- range .Categories}}
+	<wp:category><wp:category_nicename>{{cdata .Nicename}}</wp:category_nicename><wp:category_parent>{{cdata .Parent}}</wp:category_parent><wp:cat_name>{{cdata .Name}}</wp:cat_name></wp:category>
+{{- end}}
+{{- range .Tags}}
+	<wp:tag><wp:tag_slug>{{cdata .Slug}}</wp:tag_slug><wp:tag_name>{{cdata .Name}}</wp:tag_name></wp:tag>
+{{- end}}
+{{- range .Items}}
+	<item>
+		<title>{{xml .Title}}</title>
+		<link>{{xml .Link}}</link>
+		<pubDate>{{xml .PubDate}}</pubDate>
+		<dc:creator>{{cdata .Creator}}</dc:creator>
+		<guid isPermaLink="false">{{xml .Guid}}</guid>
+		<description></description>
+		<content:encoded>{{cdata .Content}}</content:encoded>
+		<excerpt:encoded>{{cdata .Excerpt}}</excerpt:encoded>
+		<wp:post_id>{{xml .PostId}}</wp:post_id>
+		<wp:post_date>{{cdata .PostDate}}</wp:post_date>
+		<wp:post_date_gmt>{{cdata .PostDateGmt}}</wp:post_date_gmt>
+		<wp:post_modified_gmt>{{cdata .PostModifiedGmt}}</wp:post_modified_gmt>
+		<wp:comment_status><![CDATA[open]]></wp:comment_status>
+		<wp:ping_status><![CDATA[open]]></wp:ping_status>
+		<wp:post_name>{{cdata .PostName}}</wp:post_name>
+		<wp:status>{{cdata .Status}}</wp:status>
+		<wp:post_parent>{{xml .PostParent}}</wp:post_parent>
+		<wp:menu_order>0</wp:menu_order>
+		<wp:post_type>{{cdata .PostType}}</wp:post_type>
+		<wp:post_password><![CDATA[]]></wp:post_password>
+		<wp:is_sticky>0</wp:is_sticky>
+{{- if .AttachmentUrl}}
+		<wp:attachment_url>{{cdata .AttachmentUrl}}</wp:attachment_url>
+{{- end}}
+{{- range .Categories}}
+		<category domain="{{xml .Domain}}" nicename="{{xml .Nicename}}">{{cdata .Name}}</category>
+{{- end}}
+{{- range .Comments}}
+		<wp:comment>
+			<wp:comment_id>{{xml .Id}}</wp:comment_id>
+			<wp:comment_author>{{cdata .Author}}</wp:comment_author>
+			<wp:comment_author_email>{{cdata .AuthorEmail}}</wp:comment_author_email>
+			<wp:comment_author_url>{{xml .AuthorUrl}}</wp:comment_author_url>
+			<wp:comment_date>{{cdata .Date}}</wp:comment_date>
+			<wp:comment_date_gmt>{{cdata .DateGmt}}</wp:comment_date_gmt>
+			<wp:comment_content>{{cdata .Content}}</wp:comment_content>
+			<wp:comment_approved>{{cdata .Approved}}</wp:comment_approved>
+			<wp:comment_parent>{{xml .Parent}}</wp:comment_parent>
+		</wp:comment>
+{{- end}}
+	</item>
+{{- end}}
+</channel>
+</rss>
+`))
+
+// Make sure a URL has a scheme and host so it can be written as the site's URL
+func validateWxrBlogUrl(blogUrl string) error {
+	if blogUrl == "" {
+		return nil
+	}
+	parsed, err := url.Parse(blogUrl)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return fmt.Errorf("blog_url must be a full URL, such as https://example.com: %s", blogUrl)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"io/fs"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/slashtechno/cross-blogger/cmd"
@@ -19,6 +20,8 @@ func init() {
 }
 
 func initConfig() {
+	// Set the log format from the flag or environment variable now so every log is in it, then again once the config file is read
+	setLogFormat()
 	// Tell Viper to use the prefix "CROSS_BLOGGER" for environment variables
 	internal.ConfigViper.SetEnvPrefix("CROSS_BLOGGER")
 	internal.CredentialViper.SetEnvPrefix("CROSS_BLOGGER")
@@ -47,6 +50,22 @@ func initConfig() {
 			internal.CredentialViper.SetDefault("llm_api_key", "")
 			internal.CredentialViper.SetDefault("llm_base_url", "")
 			internal.CredentialViper.SetDefault("llm_model", "")
+			// Git stuff
+			internal.CredentialViper.SetDefault("forge_token", "")
+			internal.CredentialViper.SetDefault("git_token", "")
+			internal.CredentialViper.SetDefault("git_ssh_passphrase", "")
+			internal.CredentialViper.SetDefault("git_signing_passphrase", "")
+			// Announcement stuff
+			internal.CredentialViper.SetDefault("mastodon_token", "")
+			internal.CredentialViper.SetDefault("bluesky_app_password", "")
+			// API destinations
+			internal.CredentialViper.SetDefault("hashnode_token", "")
+			internal.CredentialViper.SetDefault("medium_token", "")
+			internal.CredentialViper.SetDefault("micropub_token", "")
+			internal.CredentialViper.SetDefault("webhook_secrets", map[string]string{})
+			// Secrets for the serve command's hooks
+			internal.CredentialViper.SetDefault("hook_secrets", map[string]string{})
+			internal.CredentialViper.SetDefault("api_token", "")
 			// db stuff
 			// internal.CredentialViper.SetDefault("db", map[string]interface{}{
 			// 	"enable": false,
@@ -119,6 +138,24 @@ func initConfig() {
 			log.Fatal("Failed to read config file:", err)
 		}
 	}
+	setLogFormat()
+	platforms.Audit.File = internal.ConfigViper.GetString("audit_log")
+}
+
+// Set the format of logs from log_format
+func setLogFormat() {
+	switch strings.ToLower(internal.ConfigViper.GetString("log_format")) {
+	case "", "text":
+		log.SetFormatter(log.TextFormatter)
+	case "json":
+		log.SetFormatter(log.JSONFormatter)
+		// Machine-readable logs get machine-readable timestamps
+		log.SetTimeFormat(time.RFC3339)
+	case "logfmt":
+		log.SetFormatter(log.LogfmtFormatter)
+	default:
+		log.Warn("Invalid log format passed, using text", "passed", internal.ConfigViper.GetString("log_format"))
+	}
 }
 
 func main() {
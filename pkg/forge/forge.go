@@ -0,0 +1,115 @@
+package forge
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// ErrPullRequestExists is wrapped by the error CreatePullRequest returns if a pull request is already open for the head branch
+var ErrPullRequestExists = errors.New("a pull request already exists for the branch")
+
+// PullRequest holds what's needed to open a pull request
+type PullRequest struct {
+	// Repository is the owner and name of the repository, such as "slashtechno/cross-blogger"
+	Repository string
+	Title      string
+	Body       string
+	// Head is the branch with the changes and Base is the branch they should be merged into
+	Head string
+	Base string
+}
+
+// Client opens pull requests on a Git forge
+type Client interface {
+	// CreatePullRequest opens a pull request and returns its URL
+	CreatePullRequest(PullRequest) (string, error)
+}
+
+// New returns a client for the forge type ("github" or "gitea").
+// apiUrl is the base URL of the forge's API. For GitHub, it defaults to https://api.github.com.
+// For Gitea (and Forgejo), it's the URL of the instance, such as https://gitea.example.com.
+func New(forgeType string, apiUrl string, token string) (Client, error) {
+	switch forgeType {
+	case "github":
+		if apiUrl == "" {
+			apiUrl = "https://api.github.com"
+		}
+		return GitHub{ApiUrl: strings.TrimSuffix(apiUrl, "/"), Token: token}, nil
+	case "gitea":
+		if apiUrl == "" {
+			return nil, fmt.Errorf("a URL is required for gitea")
+		}
+		return Gitea{Url: strings.TrimSuffix(apiUrl, "/"), Token: token}, nil
+	default:
+		return nil, fmt.Errorf("unknown forge type: %s", forgeType)
+	}
+}
+
+// GitHub opens pull requests through the GitHub REST API
+type GitHub struct {
+	ApiUrl string
+	Token  string
+}
+
+func (g GitHub) CreatePullRequest(pr PullRequest) (string, error) {
+	resp, err := resty.New().R().
+		SetHeader("Authorization", "Bearer "+g.Token).
+		SetHeader("Accept", "application/vnd.github+json").
+		SetBody(map[string]interface{}{
+			"title": pr.Title,
+			"body":  pr.Body,
+			"head":  pr.Head,
+			"base":  pr.Base,
+		}).
+		SetResult(&map[string]interface{}{}).
+		ForceContentType("application/json").
+		Post(g.ApiUrl + "/repos/" + pr.Repository + "/pulls")
+	if err != nil {
+		return "", err
+	}
+	return pullRequestUrl(resp)
+}
+
+// Gitea opens pull requests through the Gitea API. Forgejo uses the same API.
+type Gitea struct {
+	Url   string
+	Token string
+}
+
+func (g Gitea) CreatePullRequest(pr PullRequest) (string, error) {
+	resp, err := resty.New().R().
+		SetHeader("Authorization", "token "+g.Token).
+		SetBody(map[string]interface{}{
+			"title": pr.Title,
+			"body":  pr.Body,
+			"head":  pr.Head,
+			"base":  pr.Base,
+		}).
+		SetResult(&map[string]interface{}{}).
+		ForceContentType("application/json").
+		Post(g.Url + "/api/v1/repos/" + pr.Repository + "/pulls")
+	if err != nil {
+		return "", err
+	}
+	return pullRequestUrl(resp)
+}
+
+// Both GitHub and Gitea return the web URL of the pull request as html_url
+func pullRequestUrl(resp *resty.Response) (string, error) {
+	// GitHub responds with 422 and Gitea with 409 if there's already a pull request for the branch
+	if (resp.StatusCode() == 422 || resp.StatusCode() == 409) && strings.Contains(strings.ToLower(resp.String()), "already exists") {
+		return "", fmt.Errorf("%w: %s", ErrPullRequestExists, resp.String())
+	}
+	if resp.StatusCode() != 201 {
+		return "", fmt.Errorf("failed to create pull request: %s", resp.String())
+	}
+	result := (*resp.Result().(*map[string]interface{}))
+	url, ok := result["html_url"].(string)
+	if !ok {
+		return "", fmt.Errorf("html_url not found in response or is not a string")
+	}
+	return url, nil
+}
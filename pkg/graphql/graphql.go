@@ -0,0 +1,70 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Client sends queries and mutations to a GraphQL endpoint
+type Client struct {
+	// Url is the URL of the endpoint, such as https://gql.hashnode.com
+	Url string
+	// Headers are sent with every request, such as for authentication
+	Headers map[string]string
+}
+
+// Error is an error returned by the endpoint
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path"`
+}
+
+// Errors are the errors returned by the endpoint for a request
+type Errors []Error
+
+func (e Errors) Error() string {
+	messages := []string{}
+	for _, err := range e {
+		messages = append(messages, err.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Do sends the query with the variables and unmarshals the data of the response into result.
+// If the response has errors, they're returned as Errors.
+func (c Client) Do(query string, variables map[string]interface{}, result interface{}) error {
+	var response struct {
+		Data   json.RawMessage `json:"data"`
+		Errors Errors          `json:"errors"`
+	}
+	resp, err := resty.New().R().
+		SetHeaders(c.Headers).
+		SetBody(map[string]interface{}{
+			"query":     query,
+			"variables": variables,
+		}).
+		SetResult(&response).
+		SetError(&response).
+		ForceContentType("application/json").
+		Post(c.Url)
+	if err != nil {
+		return err
+	}
+	if len(response.Errors) > 0 {
+		return response.Errors
+	}
+	if resp.StatusCode() != 200 {
+		return fmt.Errorf("request failed: %s", resp.Status())
+	}
+	if result == nil {
+		return nil
+	}
+	if len(response.Data) == 0 || string(response.Data) == "null" {
+		return errors.New("response has no data")
+	}
+	return json.Unmarshal(response.Data, result)
+}
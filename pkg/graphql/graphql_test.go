@@ -0,0 +1,127 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestClientDo(t *testing.T) {
+	type post struct {
+		Id    string `json:"id"`
+		Title string `json:"title"`
+	}
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		result   interface{}
+		want     interface{}
+		// wantErrors is set if the endpoint's errors should be returned
+		wantErrors Errors
+		wantErr    bool
+	}{
+		{
+			name:     "data",
+			status:   http.StatusOK,
+			response: `{"data":{"post":{"id":"1","title":"Hello"}}}`,
+			result:   &struct{ Post post }{},
+			want:     &struct{ Post post }{Post: post{Id: "1", Title: "Hello"}},
+		},
+		{
+			name:     "no result wanted",
+			status:   http.StatusOK,
+			response: `{"data":{"removePost":{"id":"1"}}}`,
+		},
+		{
+			name:       "errors with a 200",
+			status:     http.StatusOK,
+			response:   `{"data":null,"errors":[{"message":"Post not found","path":["post"]},{"message":"Try again"}]}`,
+			result:     &struct{ Post post }{},
+			wantErrors: Errors{{Message: "Post not found", Path: []interface{}{"post"}}, {Message: "Try again"}},
+		},
+		{
+			name:       "errors with another status",
+			status:     http.StatusUnauthorized,
+			response:   `{"errors":[{"message":"Invalid token"}]}`,
+			result:     &struct{ Post post }{},
+			wantErrors: Errors{{Message: "Invalid token"}},
+		},
+		{
+			name:     "failed without errors",
+			status:   http.StatusBadGateway,
+			response: `{}`,
+			result:   &struct{ Post post }{},
+			wantErr:  true,
+		},
+		{
+			name:     "no data",
+			status:   http.StatusOK,
+			response: `{"data":null}`,
+			result:   &struct{ Post post }{},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var request struct {
+				Query     string                 `json:"query"`
+				Variables map[string]interface{} `json:"variables"`
+			}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost {
+					t.Errorf("method = %s, want POST", r.Method)
+				}
+				if got := r.Header.Get("Authorization"); got != "token" {
+					t.Errorf("Authorization header = %q, want %q", got, "token")
+				}
+				if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+					t.Errorf("failed to decode the request: %v", err)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			client := Client{Url: server.URL, Headers: map[string]string{"Authorization": "token"}}
+			err := client.Do("query Post($id: ID!) { post(id: $id) { id title } }", map[string]interface{}{"id": "1"}, tt.result)
+
+			if request.Query != "query Post($id: ID!) { post(id: $id) { id title } }" || !reflect.DeepEqual(request.Variables, map[string]interface{}{"id": "1"}) {
+				t.Errorf("request = %+v, want the query and variables", request)
+			}
+			if tt.wantErrors != nil {
+				var gqlErrors Errors
+				if !errors.As(err, &gqlErrors) {
+					t.Fatalf("Do() error = %v, want Errors", err)
+				}
+				if !reflect.DeepEqual(gqlErrors, tt.wantErrors) {
+					t.Errorf("Do() errors = %+v, want %+v", gqlErrors, tt.wantErrors)
+				}
+				return
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Do() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			if !reflect.DeepEqual(tt.result, tt.want) {
+				t.Errorf("result = %+v, want %+v", tt.result, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorsError(t *testing.T) {
+	err := Errors{{Message: "Post not found"}, {Message: "Try again"}}
+	if got, want := err.Error(), "Post not found; Try again"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}